@@ -0,0 +1,296 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the audit log. Every execution of a tool, async task,
+// scratch space operation or resource command is recorded as one JSON line in
+// a dedicated, append-only file, which is rotated when it grows too large.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AuditConfig configures the audit log.
+type AuditConfig struct {
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"maxSizeMB,omitempty"`  // Rotate when the file exceeds this size (default: 100)
+	MaxBackups int    `yaml:"maxBackups,omitempty"` // Number of rotated files to keep (default: 5)
+}
+
+const (
+	defaultAuditMaxSizeMB  = 100
+	defaultAuditMaxBackups = 5
+
+	// maxAuditValueLen limits the length of parameter values in audit
+	// records, so that e.g. file contents do not bloat the log.
+	maxAuditValueLen = 1024
+
+	redactedValue = "[REDACTED]"
+)
+
+// AuditRecord is a single entry of the audit log.
+type AuditRecord struct {
+	Time        time.Time         `json:"time"`
+	Caller      string            `json:"caller"`
+	AuthMethod  string            `json:"authMethod,omitempty"`
+	Kind        string            `json:"kind"` // "tool", "async", "scratch" or "resource"
+	Tool        string            `json:"tool,omitempty"`
+	Resource    string            `json:"resource,omitempty"`
+	TaskID      string            `json:"taskId,omitempty"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	Status      string            `json:"status"` // "started", "success", "failed", "timeout" or "cancelled"
+	ExitCode    *int              `json:"exitCode,omitempty"`
+	DurationMs  int64             `json:"durationMs"`
+	OutputBytes int               `json:"outputBytes"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// AuditLog writes audit records to a file. A nil *AuditLog discards all
+// records, so callers do not need to check whether auditing is enabled.
+type AuditLog struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewAuditLog opens (or creates) the audit log described by cfg.
+func NewAuditLog(cfg *AuditConfig) (*AuditLog, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("audit log requires a path")
+	}
+	a := &AuditLog{
+		path:       cfg.Path,
+		maxSize:    int64(cfg.MaxSizeMB) << 20,
+		maxBackups: cfg.MaxBackups,
+	}
+	if a.maxSize <= 0 {
+		a.maxSize = defaultAuditMaxSizeMB << 20
+	}
+	if a.maxBackups <= 0 {
+		a.maxBackups = defaultAuditMaxBackups
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// reload applies cfg to the audit log and opens its file again, which also
+// picks up a file moved away by an external log rotation. On error the
+// current file stays in use.
+func (a *AuditLog) reload(cfg *AuditConfig) error {
+	loaded, err := NewAuditLog(cfg)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file != nil {
+		a.file.Close()
+	}
+	a.path, a.maxSize, a.maxBackups = loaded.path, loaded.maxSize, loaded.maxBackups
+	a.file, a.size = loaded.file, loaded.size
+	return nil
+}
+
+func (a *AuditLog) open() error {
+	file, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", a.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open audit log %s: %w", a.path, err)
+	}
+	a.file = file
+	a.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file to path.1, shifting older files up and
+// dropping the oldest, and starts a new file. If the file cannot be renamed,
+// writing continues at its end. If it cannot be opened again, a.file is nil
+// and opening it is retried with the next record.
+func (a *AuditLog) rotate() error {
+	a.file.Close()
+	a.file = nil
+	for i := a.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+	}
+	if err := os.Rename(a.path, a.path+".1"); err != nil {
+		if openErr := a.open(); openErr != nil {
+			log.Printf("ERROR: %v", openErr)
+		}
+		return fmt.Errorf("failed to rotate audit log %s: %w", a.path, err)
+	}
+	return a.open()
+}
+
+// Record fills in the time and caller of a record and appends it to the log.
+// Failures are logged but never fail the operation being audited.
+func (a *AuditLog) Record(ctx context.Context, record AuditRecord) {
+	if a == nil {
+		return
+	}
+	record.Time = time.Now().UTC()
+	record.Caller = principalName(ctx)
+	if principal := PrincipalFromContext(ctx); principal != nil {
+		record.AuthMethod = principal.Method
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("ERROR: Failed to encode audit record: %v", err)
+		return
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		if err := a.open(); err != nil {
+			log.Printf("ERROR: %v", err)
+			return
+		}
+	}
+	if a.size > 0 && a.size+int64(len(data)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			log.Printf("ERROR: %v", err)
+			if a.file == nil {
+				return
+			}
+		}
+	}
+	n, err := a.file.Write(data)
+	a.size += int64(n)
+	if err != nil {
+		log.Printf("ERROR: Failed to write audit log %s: %v", a.path, err)
+	}
+}
+
+// Close closes the audit log file.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+// auditParameters renders tool parameters for an audit record. Values of
+// parameters marked as secret are redacted and long values are shortened.
+func auditParameters(definitions []ToolParameter, params map[string]interface{}) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	secret := make(map[string]bool)
+	for _, def := range definitions {
+		if def.Secret {
+			secret[def.Name] = true
+		}
+	}
+
+	rendered := make(map[string]string, len(params))
+	for name, value := range params {
+		s := formatParamValue(value)
+		if secret[name] {
+			s = redactedValue
+		} else if len(s) > maxAuditValueLen {
+			s = s[:maxAuditValueLen] + "..."
+		}
+		rendered[name] = s
+	}
+	return rendered
+}
+
+// auditStatus classifies the error returned by the executor.
+func auditStatus(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, errCommandTimeout):
+		return "timeout"
+	case errors.Is(err, errCommandCancelled):
+		return "cancelled"
+	default:
+		return "failed"
+	}
+}
+
+// commandRecord builds the audit record of a command execution.
+func commandRecord(kind string, exitCode int, duration time.Duration, outputBytes int, err error) AuditRecord {
+	record := AuditRecord{
+		Kind:        kind,
+		Status:      auditStatus(err),
+		DurationMs:  duration.Milliseconds(),
+		OutputBytes: outputBytes,
+	}
+	if exitCode >= 0 {
+		record.ExitCode = &exitCode
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// wrapScratch records every call of a scratch space tool.
+func (a *AuditLog) wrapScratch(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if a == nil {
+		return handler
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		startTime := time.Now()
+		result, err := handler(ctx, request)
+
+		record := AuditRecord{
+			Kind:       "scratch",
+			Tool:       name,
+			Parameters: auditParameters(nil, request.GetArguments()),
+			Status:     "success",
+			DurationMs: time.Since(startTime).Milliseconds(),
+		}
+		if result != nil {
+			for _, content := range result.Content {
+				if text, ok := content.(mcp.TextContent); ok {
+					record.OutputBytes += len(text.Text)
+					if result.IsError {
+						record.Error = text.Text
+					}
+				}
+			}
+			if result.IsError {
+				record.Status = "failed"
+			}
+		}
+		if err != nil {
+			record.Status = "failed"
+			record.Error = err.Error()
+		}
+		a.Record(ctx, record)
+		return result, err
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readAuditRecords(t *testing.T, path string) []AuditRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditLog_Tool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := NewAuditLog(&AuditConfig{Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer auditLog.Close()
+
+	item := ContextItem{
+		Name:    "login",
+		Command: "echo $_MCP_VAR_user",
+		Parameters: []ToolParameter{
+			{Name: "user"},
+			{Name: "password", Secret: true},
+		},
+	}
+	params := map[string]interface{}{"user": "alice", "password": "hunter2"}
+	ctx := withPrincipal(context.Background(), &Principal{Name: "ci-bot", Method: "bearer"})
+
+	if _, err := handleSyncTask(ctx, item, params, auditLog, t.TempDir(), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	item.Command = "exit 3"
+	handleSyncTask(ctx, item, params, auditLog, t.TempDir(), false)
+
+	records := readAuditRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	r := records[0]
+	if r.Caller != "ci-bot" || r.AuthMethod != "bearer" || r.Kind != "tool" || r.Tool != "login" {
+		t.Errorf("unexpected record: %+v", r)
+	}
+	if r.Status != "success" || r.ExitCode == nil || *r.ExitCode != 0 || r.OutputBytes != len("alice\n") {
+		t.Errorf("unexpected result in record: %+v", r)
+	}
+	if r.Parameters["user"] != "alice" || r.Parameters["password"] != redactedValue {
+		t.Errorf("unexpected parameters: %v", r.Parameters)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "hunter2") {
+		t.Error("secret parameter leaked into the audit log")
+	}
+	if records[1].Status != "failed" || records[1].ExitCode == nil || *records[1].ExitCode != 3 {
+		t.Errorf("unexpected record of failed command: %+v", records[1])
+	}
+}
+
+func TestAuditLog_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := NewAuditLog(&AuditConfig{Path: path, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer auditLog.Close()
+	auditLog.maxSize = 300 // A few records per file
+
+	for i := 0; i < 20; i++ {
+		auditLog.Record(context.Background(), AuditRecord{Kind: "resource", Resource: "simple-mcp://test", Status: "success"})
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+		if info.Size() > auditLog.maxSize {
+			t.Errorf("%s is larger than the limit: %d bytes", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups, found %s", path+".3")
+	}
+}
+
+func TestAuditLog_RotationFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := NewAuditLog(&AuditConfig{Path: path, MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer auditLog.Close()
+	auditLog.maxSize = 300
+
+	// The log cannot be renamed over a directory that is not empty.
+	if err := os.MkdirAll(filepath.Join(path+".1", "dir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		auditLog.Record(context.Background(), AuditRecord{Kind: "resource", Resource: "simple-mcp://test", Status: "success"})
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 10 {
+		t.Errorf("expected all 10 records to be kept in the log, got %d", n)
+	}
+}
@@ -0,0 +1,412 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides native authentication for the HTTP endpoint. Clients
+// authenticate with a static bearer token, HTTP Basic credentials checked
+// against bcrypt hashes, or a JWT verified against a local JWKS file. The
+// authenticated principal is stored in the request context, where tool and
+// resource handlers can retrieve it.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthConfig selects the authentication methods accepted by the HTTP
+// endpoint. Any configured method is sufficient to authenticate a request.
+type AuthConfig struct {
+	BearerTokensFile string     `yaml:"bearerTokensFile,omitempty"`
+	BasicAuthFile    string     `yaml:"basicAuthFile,omitempty"`
+	JWT              *JWTConfig `yaml:"jwt,omitempty"`
+}
+
+// JWTConfig describes how OAuth2 access tokens in JWT format are validated.
+type JWTConfig struct {
+	JWKSFile       string `yaml:"jwksFile"`
+	Issuer         string `yaml:"issuer,omitempty"`
+	Audience       string `yaml:"audience,omitempty"`
+	PrincipalClaim string `yaml:"principalClaim,omitempty"` // Defaults to "sub"
+	RolesClaim     string `yaml:"rolesClaim,omitempty"`     // Claim listing the caller's roles
+}
+
+// Principal identifies the authenticated caller of a request.
+type Principal struct {
+	Name   string
+	Method string                 // "bearer", "basic", "jwt" or "mtls"
+	Claims map[string]interface{} // Claims of a JWT, nil for other methods
+	Roles  []string               // Roles asserted by the token, see JWTConfig.RolesClaim
+}
+
+type principalKey struct{}
+
+// withPrincipal returns a copy of ctx carrying the authenticated principal.
+func withPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller of the current
+// request, or nil if authentication is disabled.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// principalName returns the name of the caller for log messages.
+func principalName(ctx context.Context) string {
+	if principal := PrincipalFromContext(ctx); principal != nil {
+		return principal.Name
+	}
+	return "anonymous"
+}
+
+var errUnauthenticated = errors.New("missing or invalid credentials")
+
+// dummyHash is compared against when an unknown user name is given, so that
+// the response time does not reveal which user names exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("simple-mcp"), bcrypt.DefaultCost)
+
+// Authenticator checks the credentials of incoming HTTP requests. The
+// credentials can be replaced with reload while requests are served.
+type Authenticator struct {
+	mu     sync.RWMutex
+	tokens map[[sha256.Size]byte]string // SHA-256 of token -> principal name
+	users  map[string][]byte            // User name -> bcrypt hash
+	jwt    *JWTConfig
+	keys   map[string]interface{} // Key ID -> public key
+}
+
+// NewAuthenticator loads the credential files referenced by cfg.
+func NewAuthenticator(cfg *AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{}
+
+	if cfg.BearerTokensFile != "" {
+		tokens, err := loadBearerTokens(cfg.BearerTokensFile)
+		if err != nil {
+			return nil, err
+		}
+		a.tokens = tokens
+	}
+
+	if cfg.BasicAuthFile != "" {
+		users, err := loadBasicAuthFile(cfg.BasicAuthFile)
+		if err != nil {
+			return nil, err
+		}
+		a.users = users
+	}
+
+	if cfg.JWT != nil {
+		if cfg.JWT.JWKSFile == "" {
+			return nil, fmt.Errorf("jwt authentication requires jwksFile")
+		}
+		keys, err := loadJWKS(cfg.JWT.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = cfg.JWT
+		a.keys = keys
+	}
+
+	if a.tokens == nil && a.users == nil && a.jwt == nil {
+		return nil, fmt.Errorf("auth section does not enable any authentication method")
+	}
+	return a, nil
+}
+
+// replace switches to the credentials of loaded, which was created by
+// NewAuthenticator from a reloaded configuration, e.g. after a token was
+// revoked.
+func (a *Authenticator) replace(loaded *Authenticator) {
+	loaded.mu.RLock()
+	defer loaded.mu.RUnlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokens, a.users, a.jwt, a.keys = loaded.tokens, loaded.users, loaded.jwt, loaded.keys
+}
+
+// readCredentialLines calls fn for every non-empty, non-comment line of a
+// file. Errors are reported with the file name and line number.
+func readCredentialLines(path string, fn func(line string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadBearerTokens reads a file with one "name:token" pair per line. The
+// name is used as the principal of requests presenting the token.
+func loadBearerTokens(path string) (map[[sha256.Size]byte]string, error) {
+	tokens := make(map[[sha256.Size]byte]string)
+	err := readCredentialLines(path, func(line string) error {
+		name, token, ok := strings.Cut(line, ":")
+		if !ok || name == "" || token == "" {
+			return fmt.Errorf("expected name:token")
+		}
+		tokens[sha256.Sum256([]byte(token))] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// loadBasicAuthFile reads an htpasswd-style file with one "user:hash" pair
+// per line. Only bcrypt hashes are accepted, as created by `htpasswd -B`.
+func loadBasicAuthFile(path string) (map[string][]byte, error) {
+	users := make(map[string][]byte)
+	err := readCredentialLines(path, func(line string) error {
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return fmt.Errorf("expected user:hash")
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("password of user %s is not a bcrypt hash", user)
+		}
+		users[user] = []byte(hash)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// loadJWKS reads the public keys from a JSON Web Key Set file. RSA, EC and
+// Ed25519 keys are supported.
+func loadJWKS(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file %s: %w", path, err)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file %s: %w", path, err)
+	}
+
+	keys := make(map[string]interface{})
+	for i, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key interface{}
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			var n, e []byte
+			if n, err = base64.RawURLEncoding.DecodeString(jwk.N); err == nil {
+				e, err = base64.RawURLEncoding.DecodeString(jwk.E)
+			}
+			if err == nil {
+				key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+			}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				err = fmt.Errorf("unsupported curve %q", jwk.Crv)
+			}
+			var x, y []byte
+			if err == nil {
+				if x, err = base64.RawURLEncoding.DecodeString(jwk.X); err == nil {
+					y, err = base64.RawURLEncoding.DecodeString(jwk.Y)
+				}
+			}
+			if err == nil {
+				key = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			}
+		case "OKP":
+			var x []byte
+			if jwk.Crv != "Ed25519" {
+				err = fmt.Errorf("unsupported curve %q", jwk.Crv)
+			} else if x, err = base64.RawURLEncoding.DecodeString(jwk.X); err == nil {
+				if len(x) != ed25519.PublicKeySize {
+					err = fmt.Errorf("invalid Ed25519 key length")
+				}
+				key = ed25519.PublicKey(x)
+			}
+		default:
+			err = fmt.Errorf("unsupported key type %q", jwk.Kty)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d in JWKS file %s: %w", i, path, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s contains no signing keys", path)
+	}
+	return keys, nil
+}
+
+// Authenticate checks the Authorization header of r and returns the
+// principal it identifies.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if user, password, ok := r.BasicAuth(); ok {
+		if a.users == nil {
+			return nil, errUnauthenticated
+		}
+		hash, known := a.users[user]
+		if !known {
+			hash = dummyHash
+		}
+		if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !known {
+			return nil, errUnauthenticated
+		}
+		return &Principal{Name: user, Method: "basic"}, nil
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, errUnauthenticated
+	}
+
+	if name, ok := a.tokens[sha256.Sum256([]byte(token))]; ok {
+		return &Principal{Name: name, Method: "bearer"}, nil
+	}
+
+	if a.jwt != nil {
+		return a.verifyJWT(token)
+	}
+	return nil, errUnauthenticated
+}
+
+// verifyJWT validates the signature, expiry, issuer and audience of a JWT.
+// The caller must hold a.mu.
+func (a *Authenticator) verifyJWT(token string) (*Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+	}
+	if a.jwt.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.jwt.Issuer))
+	}
+	if a.jwt.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.jwt.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := a.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnauthenticated, err)
+	}
+
+	claimName := a.jwt.PrincipalClaim
+	if claimName == "" {
+		claimName = "sub"
+	}
+	name, _ := claims[claimName].(string)
+	if name == "" {
+		return nil, fmt.Errorf("%w: token has no %s claim", errUnauthenticated, claimName)
+	}
+	principal := &Principal{Name: name, Method: "jwt", Claims: claims}
+	if a.jwt.RolesClaim != "" {
+		switch roles := claims[a.jwt.RolesClaim].(type) {
+		case string:
+			principal.Roles = strings.Fields(roles)
+		case []interface{}:
+			for _, role := range roles {
+				if s, ok := role.(string); ok {
+					principal.Roles = append(principal.Roles, s)
+				}
+			}
+		}
+	}
+	return principal, nil
+}
+
+// Middleware rejects unauthenticated requests with 401 Unauthorized and
+// stores the principal of authenticated ones in the request context.
+// Requests already identified by a client certificate are let through.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if PrincipalFromContext(r.Context()) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			log.Printf("Rejected unauthenticated request from %s: %v", r.RemoteAddr, err)
+			a.mu.RLock()
+			if a.tokens != nil || a.jwt != nil {
+				w.Header().Add("WWW-Authenticate", `Bearer realm="simple-mcp"`)
+			}
+			if a.users != nil {
+				w.Header().Add("WWW-Authenticate", `Basic realm="simple-mcp"`)
+			}
+			a.mu.RUnlock()
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAuthenticator(t *testing.T) {
+	dir := t.TempDir()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks, _ := json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			{"kid": "rsa1", "kty": "RSA", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kid": "ec1", "kty": "EC", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+		},
+	})
+
+	authenticator, err := NewAuthenticator(&AuthConfig{
+		BearerTokensFile: writeTestFile(t, dir, "tokens", "# comment\nci-bot:s3cr3t-token\n"),
+		BasicAuthFile:    writeTestFile(t, dir, "htpasswd", "alice:"+string(hash)+"\n"),
+		JWT: &JWTConfig{
+			JWKSFile: writeTestFile(t, dir, "jwks.json", string(jwks)),
+			Issuer:   "https://idp.example.com",
+			Audience: "simple-mcp",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub": "bob",
+			"iss": "https://idp.example.com",
+			"aud": "simple-mcp",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	expiredClaims := validClaims()
+	expiredClaims["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "other"
+
+	tests := []struct {
+		name          string
+		authorization string
+		principal     string
+	}{
+		{"No credentials", "", ""},
+		{"Static token", "Bearer s3cr3t-token", "ci-bot"},
+		{"Unknown token", "Bearer wrong", ""},
+		{"Basic", "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret")), "alice"},
+		{"Basic wrong password", "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:wrong")), ""},
+		{"Basic unknown user", "Basic " + base64.StdEncoding.EncodeToString([]byte("mallory:secret")), ""},
+		{"JWT RSA", "Bearer " + sign(jwt.SigningMethodRS256, "rsa1", rsaKey, validClaims()), "bob"},
+		{"JWT EC", "Bearer " + sign(jwt.SigningMethodES256, "ec1", ecKey, validClaims()), "bob"},
+		{"JWT expired", "Bearer " + sign(jwt.SigningMethodRS256, "rsa1", rsaKey, expiredClaims), ""},
+		{"JWT wrong audience", "Bearer " + sign(jwt.SigningMethodRS256, "rsa1", rsaKey, wrongAudience), ""},
+		{"JWT unknown key", "Bearer " + sign(jwt.SigningMethodRS256, "other", rsaKey, validClaims()), ""},
+		{"JWT HMAC", "Bearer " + sign(jwt.SigningMethodHS256, "rsa1", []byte("secret"), validClaims()), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen *Principal
+			handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = PrincipalFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader("{}"))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if tt.principal == "" {
+				if rec.Code != http.StatusUnauthorized {
+					t.Errorf("expected 401, got %d", rec.Code)
+				}
+				if rec.Header().Get("WWW-Authenticate") == "" {
+					t.Error("expected WWW-Authenticate header")
+				}
+				return
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rec.Code)
+			}
+			if seen == nil || seen.Name != tt.principal {
+				t.Errorf("expected principal %s, got %+v", tt.principal, seen)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticator_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		cfg  *AuthConfig
+	}{
+		{"No method", &AuthConfig{}},
+		{"Missing token file", &AuthConfig{BearerTokensFile: filepath.Join(dir, "missing")}},
+		{"Malformed token line", &AuthConfig{BearerTokensFile: writeTestFile(t, dir, "tokens", "justatoken\n")}},
+		{"Plaintext password", &AuthConfig{BasicAuthFile: writeTestFile(t, dir, "htpasswd", "alice:secret\n")}},
+		{"JWT without JWKS", &AuthConfig{JWT: &JWTConfig{}}},
+		{"Empty JWKS", &AuthConfig{JWT: &JWTConfig{JWKSFile: writeTestFile(t, dir, "jwks.json", `{"keys":[]}`)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAuthenticator(tt.cfg); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
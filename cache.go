@@ -0,0 +1,208 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the cache of resource content. The output of the
+// command of a resource with intervalSeconds is reused for that long, so
+// clients polling the resource, SearchResources or copying resources to the
+// scratch space do not run the command again and again. Concurrent reads of a
+// resource share a single run of its command.
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// resourceCache holds the content of all cached resources.
+var resourceCache = NewResourceCache()
+
+// maxCacheEntries limits the number of cached resources. Each URI matching a
+// resource template is cached separately, so callers could otherwise grow the
+// cache without bound.
+const maxCacheEntries = 1024
+
+// ResourceCache caches the content of resources, keyed by URI.
+type ResourceCache struct {
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry
+	maxEntries int
+}
+
+// cacheEntry is the cached content of a resource.
+type cacheEntry struct {
+	item    ResourceItem // Definition the content was produced from
+	content string
+	updated time.Time // Zero until the content was loaded successfully
+	flight  *cacheFlight
+}
+
+// cacheFlight is a running evaluation of a resource, which all readers of
+// the resource wait for.
+type cacheFlight struct {
+	done    chan struct{} // Closed when the evaluation finished
+	content string
+}
+
+// resourceLoader evaluates a resource. It reports whether the evaluation
+// succeeded; failures are returned to the waiting readers, but not cached.
+type resourceLoader func(ctx context.Context) (string, bool)
+
+// NewResourceCache creates an empty cache.
+func NewResourceCache() *ResourceCache {
+	return &ResourceCache{entries: make(map[string]*cacheEntry), maxEntries: maxCacheEntries}
+}
+
+// entry returns the entry of item, which is created if there is none. A
+// changed definition, e.g. after a reload, invalidates the content. c.mu must
+// be held.
+func (c *ResourceCache) entry(item ResourceItem) *cacheEntry {
+	e := c.entries[item.URI]
+	if e != nil && reflect.DeepEqual(e.item, item) {
+		return e
+	}
+	if e == nil && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	e = &cacheEntry{item: item}
+	c.entries[item.URI] = e
+	return e
+}
+
+// evict makes room for a new entry. It drops the entries whose content is
+// outdated and, if that is not enough, the one loaded longest ago. Entries
+// being loaded are kept. c.mu must be held.
+func (c *ResourceCache) evict() {
+	var oldest string
+	for uri, e := range c.entries {
+		if e.flight != nil {
+			continue
+		}
+		if time.Since(e.updated) >= time.Duration(e.item.IntervalSeconds)*time.Second {
+			delete(c.entries, uri)
+			continue
+		}
+		if oldest == "" || e.updated.Before(c.entries[oldest].updated) {
+			oldest = uri
+		}
+	}
+	if len(c.entries) >= c.maxEntries && oldest != "" {
+		delete(c.entries, oldest)
+	}
+}
+
+// Get returns the content of item, which is loaded with load unless it was
+// loaded within its intervalSeconds. With backgroundRefresh, outdated content
+// is returned right away while it is loaded again. The returned error is that
+// of ctx if the caller stopped waiting.
+func (c *ResourceCache) Get(ctx context.Context, item ResourceItem, load resourceLoader) (string, error) {
+	c.mu.Lock()
+	e := c.entry(item)
+	if content := e.content; !e.updated.IsZero() {
+		if time.Since(e.updated) < time.Duration(item.IntervalSeconds)*time.Second {
+			c.mu.Unlock()
+			return content, nil
+		}
+		if item.BackgroundRefresh {
+			c.start(ctx, e, load)
+			c.mu.Unlock()
+			return content, nil
+		}
+	}
+	flight := c.start(ctx, e, load)
+	c.mu.Unlock()
+
+	select {
+	case <-flight.done:
+		return flight.content, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// start starts loading the entry, unless it is being loaded already, and
+// returns the running evaluation. The evaluation does not depend on the
+// reader that started it, which may go away before it finishes. Its
+// principal is kept for the audit log, but is not passed to the command, as
+// the output is shared by all readers. c.mu must be held.
+func (c *ResourceCache) start(ctx context.Context, e *cacheEntry, load resourceLoader) *cacheFlight {
+	if e.flight != nil {
+		return e.flight
+	}
+	flight := &cacheFlight{done: make(chan struct{})}
+	e.flight = flight
+	loadCtx := withPrincipal(context.Background(), PrincipalFromContext(ctx))
+	go func() {
+		content, ok := load(loadCtx)
+		c.mu.Lock()
+		if ok {
+			e.content = content
+			e.updated = time.Now()
+		}
+		e.flight = nil
+		c.mu.Unlock()
+		flight.content = content
+		close(flight.done)
+	}()
+	return flight
+}
+
+// Peek returns the cached content of item, if there is any.
+func (c *ResourceCache) Peek(item ResourceItem) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[item.URI]
+	if e == nil || e.updated.IsZero() || !reflect.DeepEqual(e.item, item) {
+		return "", false
+	}
+	return e.content, true
+}
+
+// Put stores content loaded outside of the cache, e.g. by the scheduled
+// evaluation of the resource.
+func (c *ResourceCache) Put(item ResourceItem, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entry(item)
+	e.content = content
+	e.updated = time.Now()
+}
+
+// Invalidate drops the content of uri, e.g. after a file it contains changed.
+func (c *ResourceCache) Invalidate(uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.entries[uri]; e != nil {
+		e.updated = time.Time{}
+	}
+}
+
+// Retain drops the content of the resources not in resources, e.g. after
+// they were removed from the configuration. Like the registry, resources is
+// keyed by the id of each resource, which is the template of templated ones.
+func (c *ResourceCache) Retain(resources map[string]ResourceItem) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for uri, e := range c.entries {
+		if _, ok := resources[e.item.id()]; ok {
+			continue
+		}
+		if _, ok := lookupResource(resources, uri); !ok {
+			delete(c.entries, uri)
+		}
+	}
+}
+
+// checkInterval rejects a negative intervalSeconds.
+func checkInterval(seconds int) error {
+	if seconds < 0 {
+		return fmt.Errorf("intervalSeconds must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// countingResource returns a resource whose command prints how often it ran.
+func countingResource(t *testing.T, uri string) (ResourceItem, string) {
+	dir := t.TempDir()
+	return ResourceItem{
+		URI:             uri,
+		Command:         "sleep 0.2; echo run >> " + dir + "/count; wc -l < " + dir + "/count",
+		IntervalSeconds: 60,
+	}, dir
+}
+
+func TestResourceCache(t *testing.T) {
+	item, _ := countingResource(t, "test://cached")
+
+	// Concurrent reads share a single run.
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = getResourceContent(context.Background(), item, nil, "", false)
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range results {
+		if strings.TrimSpace(result) != "1" {
+			t.Errorf("expected all reads to share the first run, got %q", result)
+		}
+	}
+
+	// The content is reused within the interval.
+	if content, _ := getResourceContent(context.Background(), item, nil, "", false); strings.TrimSpace(content) != "1" {
+		t.Errorf("expected the cached content, got %q", content)
+	}
+
+	// Outdated content is loaded again.
+	resourceCache.mu.Lock()
+	resourceCache.entries[item.URI].updated = time.Now().Add(-time.Hour)
+	resourceCache.mu.Unlock()
+	if content, _ := getResourceContent(context.Background(), item, nil, "", false); strings.TrimSpace(content) != "2" {
+		t.Errorf("expected the content to be loaded again, got %q", content)
+	}
+
+	// A changed definition is loaded again.
+	item.Description = "changed"
+	if content, _ := getResourceContent(context.Background(), item, nil, "", false); strings.TrimSpace(content) != "3" {
+		t.Errorf("expected the changed resource to be loaded again, got %q", content)
+	}
+
+	resourceCache.Retain(nil)
+	if len(resourceCache.entries) != 0 {
+		t.Errorf("expected the cache to be empty, got %d entries", len(resourceCache.entries))
+	}
+}
+
+func TestResourceCache_BackgroundRefresh(t *testing.T) {
+	item, _ := countingResource(t, "test://background")
+	item.BackgroundRefresh = true
+	if content, _ := getResourceContent(context.Background(), item, nil, "", false); strings.TrimSpace(content) != "1" {
+		t.Fatalf("expected the first read to wait for the content, got %q", content)
+	}
+
+	resourceCache.mu.Lock()
+	resourceCache.entries[item.URI].updated = time.Now().Add(-time.Hour)
+	resourceCache.mu.Unlock()
+	start := time.Now()
+	if content, _ := getResourceContent(context.Background(), item, nil, "", false); strings.TrimSpace(content) != "1" {
+		t.Errorf("expected the outdated content, got %q", content)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected the outdated content at once, took %s", elapsed)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if content, _ := getResourceContent(context.Background(), item, nil, "", false); strings.TrimSpace(content) != "2" {
+		t.Errorf("expected the refreshed content, got %q", content)
+	}
+}
+
+func TestResourceCache_Failure(t *testing.T) {
+	item, dir := countingResource(t, "test://failing")
+	item.Command = "echo run >> " + dir + "/count; exit 1"
+	for i := 0; i < 2; i++ {
+		if content, _ := getResourceContent(context.Background(), item, nil, "", false); !strings.Contains(content, "Error executing command") {
+			t.Errorf("expected the error, got %q", content)
+		}
+	}
+	if content, _ := getResourceContent(context.Background(), ResourceItem{Command: "cat " + dir + "/count"}, nil, "", false); content != "run\nrun\n" {
+		t.Errorf("expected failures not to be cached, got %q", content)
+	}
+}
+
+func TestResourceCache_Limit(t *testing.T) {
+	cache := NewResourceCache()
+	cache.maxEntries = 3
+	load := func(ctx context.Context) (string, bool) { return "content", true }
+	get := func(uri string) {
+		t.Helper()
+		item := ResourceItem{URI: uri, IntervalSeconds: 60}
+		if content, err := cache.Get(context.Background(), item, load); content != "content" || err != nil {
+			t.Fatalf("unexpected content %q, %v", content, err)
+		}
+	}
+
+	get("test://outdated")
+	get("test://a")
+	cache.mu.Lock()
+	cache.entries["test://outdated"].updated = time.Now().Add(-time.Hour)
+	cache.mu.Unlock()
+	get("test://b")
+	get("test://c")
+	if _, ok := cache.entries["test://outdated"]; ok || len(cache.entries) != 3 {
+		t.Errorf("expected the outdated entry to be evicted first, got %d entries", len(cache.entries))
+	}
+
+	// Like the URIs of a resource template, read one after the other.
+	for i := 0; i < 10; i++ {
+		get(fmt.Sprintf("test://unit/%d", i))
+	}
+	if len(cache.entries) != 3 {
+		t.Errorf("expected 3 entries, got %d", len(cache.entries))
+	}
+	if _, ok := cache.Peek(ResourceItem{URI: "test://unit/9", IntervalSeconds: 60}); !ok {
+		t.Error("expected the latest entry to be cached")
+	}
+}
+
+func TestResourceCache_Cancel(t *testing.T) {
+	item := ResourceItem{URI: "test://slow", Command: "sleep 1", IntervalSeconds: 60}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resource := configResource(item, nil, "", false)
+	if contents, err := resource.Handler(ctx, mcp.ReadResourceRequest{}); err != context.Canceled {
+		t.Errorf("expected the cancellation to be returned, got %v, %v", contents, err)
+	}
+}
+
+func TestResourceCache_Principal(t *testing.T) {
+	item := ResourceItem{URI: "test://principal", Command: `echo "[$_MCP_PRINCIPAL]"`, IntervalSeconds: 60}
+	alice := withPrincipal(context.Background(), &Principal{Name: "alice"})
+	if content, _ := getResourceContent(alice, item, nil, "", false); content != "[]\n" {
+		t.Errorf("expected the shared output not to depend on the caller, got %q", content)
+	}
+}
+
+func TestResourceCache_Retain(t *testing.T) {
+	cache := NewResourceCache()
+	load := func(ctx context.Context) (string, bool) { return "content", true }
+	definition := ResourceItem{URITemplate: "test://unit/{name}", Command: "true", IntervalSeconds: 60}
+	item, err := definition.expandTemplate("test://unit/cron")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Get(context.Background(), item, load)
+
+	cache.Retain(map[string]ResourceItem{definition.id(): definition})
+	if _, ok := cache.Peek(item); !ok {
+		t.Error("expected the content of the template resource to be retained")
+	}
+	cache.Retain(map[string]ResourceItem{})
+	if _, ok := cache.Peek(item); ok {
+		t.Error("expected the content of the removed resource to be dropped")
+	}
+}
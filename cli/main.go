@@ -7,29 +7,59 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func main() {
 	serverAddr := flag.String("server", "localhost:8080", "Address of the simple-mcp server.")
+	token := flag.String("token", os.Getenv("SIMPLE_MCP_TOKEN"), "Bearer token for authenticating to the server (default: $SIMPLE_MCP_TOKEN).")
+	user := flag.String("user", "", "User name and password for HTTP Basic authentication, as user:password.")
+	caCert := flag.String("cacert", "", "CA certificate for verifying the server. Connects using HTTPS.")
+	clientCert := flag.String("cert", "", "Client certificate for servers requiring mutual TLS.")
+	clientKey := flag.String("key", "", "Private key of the client certificate.")
 	flag.Parse()
 
 	if len(flag.Args()) == 0 {
 		fmt.Println("Usage: simple-mcp-cli [options] <subcommand> [args]")
-		fmt.Println("Subcommands: list-tools, show-tool, list-resources, show-resource, resource, tool")
+		fmt.Println("Subcommands: list-tools, show-tool, list-resources, show-resource, resource, tool, cancel-task")
 		os.Exit(1)
 	}
 
-	baseURL := fmt.Sprintf("http://%s/mcp", *serverAddr)
-	clt, err := client.NewStreamableHttpClient(baseURL)
+	scheme := "http"
+	var httpClient *http.Client
+	if *caCert != "" || *clientCert != "" || strings.HasPrefix(*serverAddr, "https://") {
+		tlsConfig, err := clientTLSConfig(*caCert, *clientCert, *clientKey)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS: %v", err)
+		}
+		scheme = "https"
+		httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	}
+	baseURL := fmt.Sprintf("%s://%s/mcp", scheme, strings.TrimPrefix(strings.TrimPrefix(*serverAddr, "https://"), "http://"))
+	headers := make(map[string]string)
+	if *token != "" {
+		headers["Authorization"] = "Bearer " + *token
+	} else if *user != "" {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(*user))
+	}
+	clientOpts := []transport.StreamableHTTPCOption{transport.WithHTTPHeaders(headers)}
+	if httpClient != nil {
+		clientOpts = append(clientOpts, transport.WithHTTPBasicClient(httpClient))
+	}
+	clt, err := client.NewStreamableHttpClient(baseURL, clientOpts...)
 	if err != nil {
 		log.Fatalf("Failed to create WebSocket client: %v", err)
 	}
@@ -123,7 +153,11 @@ func main() {
 			case mcp.TextResourceContents:
 				fmt.Print(c.Text)
 			case mcp.BlobResourceContents:
-				fmt.Print(c.Blob)
+				data, err := base64.StdEncoding.DecodeString(c.Blob)
+				if err != nil {
+					log.Fatalf("Failed to decode resource %s: %v", c.URI, err)
+				}
+				os.Stdout.Write(data)
 			}
 		}
 	case "tool":
@@ -155,22 +189,112 @@ func main() {
 		if err != nil {
 			log.Fatalf("Failed to call tool: %v", err)
 		}
-		if callResult.IsError {
-			for _, content := range callResult.Content {
-				if textContent, ok := content.(mcp.TextContent); ok {
-					log.Fatalf("Tool returned an error: %s", textContent.Text)
-				}
-			}
-			log.Fatalf("Tool returned an unknown error")
-		} else {
-			for _, content := range callResult.Content {
-				if textContent, ok := content.(mcp.TextContent); ok {
-					fmt.Print(textContent.Text)
-				}
-			}
+		printToolResult(callResult)
+	case "cancel-task":
+		if len(flag.Args()) < 2 {
+			fmt.Println("Usage: simple-mcp-cli cancel-task <task-id>")
+			os.Exit(1)
 		}
+		callResult, err := clt.CallTool(ctx, mcp.CallToolRequest{
+			Params: mcp.CallToolParams{
+				Name:      "CancelTask",
+				Arguments: map[string]any{"taskID": flag.Arg(1)},
+			},
+		})
+		if err != nil {
+			log.Fatalf("Failed to cancel task: %v", err)
+		}
+		printToolResult(callResult)
+		fmt.Println()
 	default:
 		fmt.Printf("Unknown subcommand: %s\n", subcommand)
 		os.Exit(1)
 	}
 }
+
+// printToolResult prints the text content of a tool result, or exits with an
+// error if the tool reported a failure.
+func printToolResult(callResult *mcp.CallToolResult) {
+	if printCommandResult(callResult) {
+		return
+	}
+	if callResult.IsError {
+		for _, content := range callResult.Content {
+			if textContent, ok := content.(mcp.TextContent); ok {
+				log.Fatalf("Tool returned an error: %s", textContent.Text)
+			}
+		}
+		log.Fatalf("Tool returned an unknown error")
+	}
+	for _, content := range callResult.Content {
+		if textContent, ok := content.(mcp.TextContent); ok {
+			fmt.Print(textContent.Text)
+		}
+	}
+}
+
+// printCommandResult prints a structured result of a tool, with stdout and
+// stderr in the structured content, or in separate content blocks for older
+// servers, like the command would have, and exits with the exit code of a
+// failed command. It reports whether the result was structured.
+func printCommandResult(callResult *mcp.CallToolResult) bool {
+	structured, ok := callResult.StructuredContent.(map[string]any)
+	if !ok || len(callResult.Content) < 2 {
+		return false
+	}
+	exitCode, ok := structured["exitCode"].(float64)
+	if !ok {
+		return false
+	}
+	stdout, hasStdout := structured["stdout"].(string)
+	stderr, hasStderr := structured["stderr"].(string)
+	for i, content := range callResult.Content {
+		textContent, ok := content.(mcp.TextContent)
+		if !ok {
+			continue
+		}
+		switch {
+		case i == 0 && hasStdout:
+			fmt.Print(stdout)
+		case i == 1 && hasStderr:
+			fmt.Fprint(os.Stderr, stderr)
+		case i == 0:
+			fmt.Print(textContent.Text)
+		case i == 1:
+			fmt.Fprint(os.Stderr, textContent.Text)
+		default:
+			fmt.Fprintln(os.Stderr, textContent.Text)
+		}
+	}
+	if callResult.IsError {
+		if exitCode <= 0 {
+			exitCode = 1
+		}
+		os.Exit(int(exitCode))
+	}
+	return true
+}
+
+// clientTLSConfig builds the TLS configuration for connecting to a server
+// with a private CA and, optionally, a client certificate.
+func clientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s contains no PEM certificates", caFile)
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -16,9 +16,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"gopkg.in/yaml.v3"
 )
@@ -26,45 +29,154 @@ import (
 // ContextItem defines a single dynamic context source (Tool) exposed to the LLM.
 // Tools are executable commands that can accept parameters.
 type ContextItem struct {
-	Name           string   `yaml:"name"`
-	Description    string   `yaml:"description"`
-	Command        string   `yaml:"command"`
-	TimeoutSeconds int      `yaml:"timeoutSeconds,omitempty"`
-	Parameters     []string `yaml:"parameters,omitempty"`
-	Async          bool     `yaml:"async,omitempty"`
+	Name           string             `yaml:"name" jsonschema:"required"`
+	Description    string             `yaml:"description"`
+	Command        string             `yaml:"command"`
+	Args           []string           `yaml:"args,omitempty"`
+	TimeoutSeconds int                `yaml:"timeoutSeconds,omitempty"`
+	Parameters     []ToolParameter    `yaml:"parameters,omitempty"`
+	Async          bool               `yaml:"async,omitempty"`
+	Roles          []string           `yaml:"roles,omitempty"`
+	Env            map[string]string  `yaml:"env,omitempty"` // Added to the environment of the command and redacted from its output
+	Environment    *EnvironmentPolicy `yaml:"environment,omitempty"`
+
+	// User and groups the command runs as, by name or ID.
+	User                string   `yaml:"user,omitempty"`
+	Group               string   `yaml:"group,omitempty"`
+	SupplementaryGroups []string `yaml:"supplementaryGroups,omitempty"`
+
+	Limits  *ResourceLimits `yaml:"limits,omitempty"`
+	Sandbox bool            `yaml:"sandbox,omitempty"` // Run without network, with a read-only root and a private /tmp
+
+	MaxOutputBytes int   `yaml:"maxOutputBytes,omitempty"` // Output kept from the command, 0 for the global limit
+	SpillOutput    *bool `yaml:"spillOutput,omitempty"`    // Keep the full output in a resource when truncated
+
+	SuccessExitCodes []int `yaml:"successExitCodes,omitempty"` // Exit codes that mean success, e.g. [0, 1] for grep (default: 0)
+	StructuredOutput bool  `yaml:"structuredOutput,omitempty"` // Return stdout, stderr and the exit code separately
+
+	secrets     []string            // Values redacted from the output
+	credential  *syscall.Credential // Resolved from User and the groups
+	shared      bool                // The output is cached for all callers
+	resourceURI string              // URI of the resource running the command, if any
 }
 
 // ResourceItem defines a system resource exposed via the MCP Resources capability.
 // These can be static text content or dynamic content generated by a command.
 type ResourceItem struct {
-	URI             string `yaml:"uri"`
-	Description     string `yaml:"description"`
-	Command         string `yaml:"command,omitempty"`
-	IntervalSeconds int    `yaml:"intervalSeconds,omitempty"`
-	Content         string `yaml:"content,omitempty"`
-	ContentFile     string `yaml:"contentFile,omitempty"`
-	Directory       string `yaml:"directory,omitempty"`
+	URI               string             `yaml:"uri"`
+	URITemplate       string             `yaml:"uriTemplate,omitempty"` // RFC 6570 template of the URIs, instead of uri
+	Variables         []ToolParameter    `yaml:"variables,omitempty"`   // Validation of the variables of uriTemplate
+	Description       string             `yaml:"description"`
+	MimeType          string             `yaml:"mimeType,omitempty"` // Type of the content, derived from the file by default
+	Command           string             `yaml:"command,omitempty"`
+	Args              []string           `yaml:"args,omitempty"`
+	IntervalSeconds   int                `yaml:"intervalSeconds,omitempty"`   // How long the output of the command is cached
+	BackgroundRefresh bool               `yaml:"backgroundRefresh,omitempty"` // Return cached output at once and refresh it in the background
+	Content           string             `yaml:"content,omitempty"`
+	ContentFile       string             `yaml:"contentFile,omitempty"`
+	Directory         string             `yaml:"directory,omitempty"`
+	Roles             []string           `yaml:"roles,omitempty"`
+	Environment       *EnvironmentPolicy `yaml:"environment,omitempty"`
+
+	// User and groups the command runs as, by name or ID.
+	User                string   `yaml:"user,omitempty"`
+	Group               string   `yaml:"group,omitempty"`
+	SupplementaryGroups []string `yaml:"supplementaryGroups,omitempty"`
+
+	Limits  *ResourceLimits `yaml:"limits,omitempty"`
+	Sandbox bool            `yaml:"sandbox,omitempty"` // Run without network, with a read-only root and a private /tmp
+
+	MaxOutputBytes int   `yaml:"maxOutputBytes,omitempty"` // Output kept from the command, 0 for the global limit
+	SpillOutput    *bool `yaml:"spillOutput,omitempty"`    // Keep the full output in a resource when truncated
+
+	// Files of a directory exposed as resources. Patterns without a slash
+	// match the file name, others the path relative to the directory.
+	Include      []string `yaml:"include,omitempty"`      // Only files matching one of the patterns
+	Exclude      []string `yaml:"exclude,omitempty"`      // No files matching one of the patterns
+	MaxDepth     int      `yaml:"maxDepth,omitempty"`     // Levels of subdirectories, 1 for the directory itself only
+	MaxFileBytes int64    `yaml:"maxFileBytes,omitempty"` // Larger files are left out
+
+	secrets    []string            // Values redacted from the output
+	credential *syscall.Credential // Resolved from User and the groups
+	dirPath    string              // Resolved from Directory
+
+	// Variables extracted from the URI of a resource with a uriTemplate.
+	vars map[string]interface{}
+
+	// A resource with a contentFile or from a directory reads the file on
+	// every access, following its static content.
+	contentPath   string
+	staticContent string
+}
+
+// commandItem returns a tool running the command of the resource, for the
+// executor.
+func (r ResourceItem) commandItem() ContextItem {
+	return ContextItem{
+		Command:        r.Command,
+		Args:           r.Args,
+		Environment:    r.Environment,
+		Limits:         r.Limits,
+		Sandbox:        r.Sandbox,
+		MaxOutputBytes: r.MaxOutputBytes,
+		SpillOutput:    r.SpillOutput,
+		secrets:        r.secrets,
+		credential:     r.credential,
+		shared:         r.IntervalSeconds > 0,
+		resourceURI:    r.URI,
+	}
 }
 
 // Spec defines the schema for the configuration file.
 type Spec struct {
-	LegacyItems []ContextItem  `yaml:"contextItems,omitempty"`
-	Tools       []ContextItem  `yaml:"tools,omitempty"`
-	Resources   []ResourceItem `yaml:"resources"`
-	ListenAddr    string         `yaml:"listenAddr,omitempty"`
-	TmpDir        string         `yaml:"tmpDir,omitempty"`
-	Verbose       *bool          `yaml:"verbose,omitempty"`
-	MaxAsyncTasks int            `yaml:"maxAsyncTasks,omitempty"`
+	LegacyItems    []ContextItem      `yaml:"contextItems,omitempty"`
+	Tools          []ContextItem      `yaml:"tools,omitempty"`
+	Resources      []ResourceItem     `yaml:"resources"`
+	ListenAddr     string             `yaml:"listenAddr,omitempty"`
+	TmpDir         string             `yaml:"tmpDir,omitempty"`
+	Verbose        *bool              `yaml:"verbose,omitempty"`
+	MaxAsyncTasks  int                `yaml:"maxAsyncTasks,omitempty"`
+	StateDir       string             `yaml:"stateDir,omitempty"`
+	Auth           *AuthConfig        `yaml:"auth,omitempty"`
+	TLSCert        string             `yaml:"tlsCert,omitempty"`
+	TLSKey         string             `yaml:"tlsKey,omitempty"`
+	ClientCA       string             `yaml:"clientCA,omitempty"`
+	Transport      string             `yaml:"transport,omitempty" jsonschema:"enum=http,enum=sse,enum=stdio"`
+	Roles          []RoleDefinition   `yaml:"roles,omitempty"`
+	DefaultRole    string             `yaml:"defaultRole,omitempty"`
+	Audit          *AuditConfig       `yaml:"audit,omitempty"`
+	Include        []string           `yaml:"include,omitempty"`        // Files or globs with more tools and resources
+	Environment    *EnvironmentPolicy `yaml:"environment,omitempty"`    // Environment of all commands
+	Limits         *ResourceLimits    `yaml:"limits,omitempty"`         // Default limits of all commands
+	MaxOutputBytes int                `yaml:"maxOutputBytes,omitempty"` // Output kept from each command, 0 for no limit
+	SpillOutput    bool               `yaml:"spillOutput,omitempty"`    // Keep the full output of truncated commands in a resource
+
+	// Access to the tools and resources without roles, and to the built-in
+	// tools, when roles are defined.
+	DefaultAccess    string              `yaml:"defaultAccess,omitempty" jsonschema:"enum=allow,enum=deny"`
+	BuiltinToolRoles map[string][]string `yaml:"builtinToolRoles,omitempty"` // Built-in tool name -> roles allowed to use it
 }
 
 // Config represents the top-level structure of the simple-mcp.yaml file.
 type Config struct {
-	APIVersion string `yaml:"apiVersion"`
-	Kind       string `yaml:"kind"`
+	APIVersion string `yaml:"apiVersion" jsonschema:"required,enum=v1"`
+	Kind       string `yaml:"kind" jsonschema:"required,enum=DynamicContextSource"`
 	Metadata   struct {
 		Name string `yaml:"name"`
 	} `yaml:"metadata"`
 	Specification Spec `yaml:"spec"`
+
+	// Files lists the main file and the included files, in the order in
+	// which they were loaded.
+	Files []string `yaml:"-"`
+}
+
+// ConfigFragment is the content of an included file or of a file in the
+// drop-in directory. Its tools and resources are added to those of the main
+// configuration file.
+type ConfigFragment struct {
+	Tools     []ContextItem  `yaml:"tools,omitempty"`
+	Resources []ResourceItem `yaml:"resources,omitempty"`
 }
 
 var yamlLineRegex = regexp.MustCompile(`line (\d+):`)
@@ -100,7 +212,8 @@ func formatYamlError(path string, fileData []byte, err error) error {
 	return errors.New(newErr.String())
 }
 
-// LoadConfig reads and parses the YAML configuration file from the given path.
+// LoadConfig reads and parses the YAML configuration file from the given path,
+// together with the files it includes and the files in its drop-in directory.
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -108,7 +221,8 @@ func LoadConfig(path string) (*Config, error) {
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	secrets, err := decodeConfigFile(path, data, &config)
+	if err != nil {
 		return nil, formatYamlError(path, data, err)
 	}
 
@@ -122,47 +236,292 @@ func LoadConfig(path string) (*Config, error) {
 		config.Specification.LegacyItems = nil // Clear LegacyItems to avoid confusion
 	}
 
+	files, err := includedFiles(path, config.Specification.Include)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// Merge the tools and resources of all files, remembering where each one
+	// came from to report duplicates.
+	toolOrigin := make(map[string]string)
+	resourceOrigin := make(map[string]string)
+	var tools []ContextItem
+	var resources []ResourceItem
+	merge := func(file string, fileTools []ContextItem, fileResources []ResourceItem) error {
+		fileTools, fileResources, err := prepareItems(file, fileTools, fileResources)
+		if err != nil {
+			return err
+		}
+		for _, tool := range fileTools {
+			if origin, ok := toolOrigin[tool.Name]; ok {
+				return duplicateError("tool", tool.Name, origin, file)
+			}
+			toolOrigin[tool.Name] = file
+			tools = append(tools, tool)
+		}
+		for _, resource := range fileResources {
+			if origin, ok := resourceOrigin[resource.id()]; ok {
+				return duplicateError("resource", resource.id(), origin, file)
+			}
+			resourceOrigin[resource.id()] = file
+			resources = append(resources, resource)
+		}
+		return nil
+	}
+
+	if err := merge(path, config.Specification.Tools, config.Specification.Resources); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		fragment, fragmentSecrets, err := loadFragment(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := merge(file, fragment.Tools, fragment.Resources); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, fragmentSecrets...)
+	}
+
+	if err := config.Specification.Environment.validate(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := config.Specification.Limits.validate(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := checkMaxOutputBytes(config.Specification.MaxOutputBytes); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// Any command may print an interpolated value, so all of them are
+	// redacted from the output of every tool and resource, together with
+	// the environment values of the tool itself.
+	for i := range tools {
+		tools[i].secrets = append([]string(nil), secrets...)
+		for _, value := range tools[i].Env {
+			tools[i].secrets = append(tools[i].secrets, value)
+		}
+		sort.Strings(tools[i].secrets)
+		tools[i].Environment = config.Specification.Environment.merge(tools[i].Environment)
+		tools[i].Limits = config.Specification.Limits.merge(tools[i].Limits)
+		tools[i].MaxOutputBytes, tools[i].SpillOutput = config.Specification.outputSettings(tools[i].MaxOutputBytes, tools[i].SpillOutput)
+	}
+	for i := range resources {
+		resources[i].secrets = secrets
+		resources[i].Environment = config.Specification.Environment.merge(resources[i].Environment)
+		resources[i].Limits = config.Specification.Limits.merge(resources[i].Limits)
+		resources[i].MaxOutputBytes, resources[i].SpillOutput = config.Specification.outputSettings(resources[i].MaxOutputBytes, resources[i].SpillOutput)
+	}
+	config.Specification.Tools = tools
+	config.Specification.Resources = resources
+	config.Files = append([]string{path}, files...)
+
+	if err := validateRoles(&config.Specification); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// loadFragment reads an included file. It returns the interpolated values
+// along with its content.
+func loadFragment(path string) (*ConfigFragment, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read included file %s: %w", path, err)
+	}
+	var fragment ConfigFragment
+	secrets, err := decodeConfigFile(path, data, &fragment)
+	if err != nil {
+		return nil, nil, formatYamlError(path, data, err)
+	}
+	return &fragment, secrets, nil
+}
+
+// decodeConfigFile decodes a configuration file into out, interpolating
+// references to secrets and rejecting unknown keys. It returns the
+// interpolated values. An empty file leaves out unchanged.
+func decodeConfigFile(path string, data []byte, out interface{}) ([]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	secrets, err := interpolate(&doc, filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if errs := unknownFields(doc.Content[0], reflect.TypeOf(out)); len(errs) > 0 {
+		return nil, &yaml.TypeError{Errors: errs}
+	}
+	return secrets, doc.Decode(out)
+}
+
+// checkEnvNames rejects environment variable names that cannot be passed to
+// a command.
+func checkEnvNames(env map[string]string) error {
+	for name := range env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+func duplicateError(what, name, first, second string) error {
+	if first == second {
+		return fmt.Errorf("%s %s is defined more than once in %s", what, name, first)
+	}
+	return fmt.Errorf("%s %s is defined in both %s and %s", what, name, first, second)
+}
+
+// dropInDir returns the drop-in directory of a configuration file, e.g.
+// simple-mcp.d for simple-mcp.yaml.
+func dropInDir(path string) string {
+	base := filepath.Base(path)
+	return filepath.Join(filepath.Dir(path), strings.TrimSuffix(base, filepath.Ext(base))+".d")
+}
+
+// includedFiles returns the files matching the include patterns, which are
+// relative to the directory of the main file, followed by the *.yaml files in
+// its drop-in directory. Each file is returned only once, in sorted order
+// within each pattern.
+func includedFiles(path string, patterns []string) ([]string, error) {
+	seen := map[string]bool{filepath.Clean(path): true}
+	var files []string
+	add := func(matches []string) {
+		for _, match := range matches {
+			match = filepath.Clean(match)
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+
+	for _, pattern := range patterns {
+		matches, err := matchInclude(path, pattern)
+		if err != nil {
+			return nil, err
+		}
+		add(matches)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dropInDir(path), "*.yaml"))
+	add(matches)
+	return files, nil
+}
+
+// matchInclude returns the files matching an include pattern of the
+// configuration file at path.
+func matchInclude(path, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(path), pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %s: %w", pattern, err)
+	}
+	// A plain file name must exist, a glob may match nothing.
+	if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return nil, fmt.Errorf("included file %s does not exist", pattern)
+	}
+	return matches, nil
+}
+
+// directoryResourceURI returns the URI of a file in the directory of a
+// directory resource.
+func directoryResourceURI(baseURI, relPath string) string {
+	if !strings.HasSuffix(baseURI, "/") {
+		baseURI += "/"
+	}
+	return baseURI + strings.ReplaceAll(relPath, "\\", "/")
+}
+
+// prepareItems checks the tools and resources of one file and expands their
+// references to other files, which are relative to the directory of that file.
+func prepareItems(path string, tools []ContextItem, resources []ResourceItem) ([]ContextItem, []ResourceItem, error) {
+	for i := range tools {
+		tool := &tools[i]
+		if tool.Command != "" && len(tool.Args) > 0 {
+			return nil, nil, fmt.Errorf("failed to parse %s: tool %s: 'command' and 'args' are mutually exclusive", path, tool.Name)
+		}
+		for j := range tool.Parameters {
+			if err := tool.Parameters[j].compile(); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse %s: tool %s: %w", path, tool.Name, err)
+			}
+		}
+		if err := checkEnvNames(tool.Env); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: tool %s: %w", path, tool.Name, err)
+		}
+		if err := tool.Environment.validate(); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: tool %s: %w", path, tool.Name, err)
+		}
+		if err := tool.Limits.validate(); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: tool %s: %w", path, tool.Name, err)
+		}
+		if err := checkMaxOutputBytes(tool.MaxOutputBytes); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: tool %s: %w", path, tool.Name, err)
+		}
+		if err := checkExitCodes(tool.SuccessExitCodes); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: tool %s: %w", path, tool.Name, err)
+		}
+		credential, err := resolveCredential(tool.User, tool.Group, tool.SupplementaryGroups)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: tool %s: %w", path, tool.Name, err)
+		}
+		tool.credential = credential
+	}
+
 	// Get the directory of the config file to resolve relative paths
 	configDir := filepath.Dir(path)
 
 	var expandedResources []ResourceItem
-	for _, resource := range config.Specification.Resources {
+	for _, resource := range resources {
+		if resource.Command != "" && len(resource.Args) > 0 {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: 'command' and 'args' are mutually exclusive", path, resource.id())
+		}
+		if err := resource.Environment.validate(); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: %w", path, resource.id(), err)
+		}
+		if err := resource.Limits.validate(); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: %w", path, resource.id(), err)
+		}
+		if err := checkMaxOutputBytes(resource.MaxOutputBytes); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: %w", path, resource.id(), err)
+		}
+		if err := checkInterval(resource.IntervalSeconds); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: %w", path, resource.id(), err)
+		}
+		if err := checkMIMEType(resource.MimeType); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: %w", path, resource.id(), err)
+		}
+		credential, err := resolveCredential(resource.User, resource.Group, resource.SupplementaryGroups)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: %w", path, resource.id(), err)
+		}
+		resource.credential = credential
+		if err := resource.validateDirectory(); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: %w", path, resource.id(), err)
+		}
+		if err := resource.validateTemplate(); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: resource %s: %w", path, resource.id(), err)
+		}
 		if resource.Directory != "" {
+			// The files are listed and read when they are accessed.
 			dirPath := resource.Directory
 			if !filepath.IsAbs(dirPath) {
 				dirPath = filepath.Join(configDir, dirPath)
 			}
-			err := filepath.WalkDir(dirPath, func(path string, d os.DirEntry, err error) error {
-				if err != nil {
-					return err
-				}
-				if d.IsDir() {
-					return nil
-				}
-				relPath, err := filepath.Rel(dirPath, path)
-				if err != nil {
-					return err
-				}
-				fileContent, err := os.ReadFile(path)
-				if err != nil {
-					return err
-				}
-				newURI := resource.URI
-				if !strings.HasSuffix(newURI, "/") {
-					newURI += "/"
-				}
-				newURI += strings.ReplaceAll(relPath, "\\", "/")
-
-				newResource := resource
-				newResource.URI = newURI
-				newResource.Content = string(fileContent)
-				newResource.Directory = "" // Clear the directory field to avoid re-expansion
-				expandedResources = append(expandedResources, newResource)
-				return nil
-			})
-			if err != nil {
-				return nil, fmt.Errorf("failed to walk directory for resource %s: %w", resource.URI, err)
+			if info, err := os.Stat(dirPath); err != nil {
+				return nil, nil, fmt.Errorf("failed to walk directory for resource %s: %w", resource.URI, err)
+			} else if !info.IsDir() {
+				return nil, nil, fmt.Errorf("failed to walk directory for resource %s: %s is not a directory", resource.URI, dirPath)
 			}
+			resource.dirPath, _ = filepath.Abs(dirPath)
+			expandedResources = append(expandedResources, resource)
 		} else {
 			if resource.ContentFile != "" {
 				contentFilePath := resource.ContentFile
@@ -171,15 +530,15 @@ func LoadConfig(path string) (*Config, error) {
 				}
 				fileContent, err := os.ReadFile(contentFilePath)
 				if err != nil {
-					return nil, fmt.Errorf("failed to read content file for resource %s: %w", resource.URI, err)
+					return nil, nil, fmt.Errorf("failed to read content file for resource %s: %w", resource.URI, err)
 				}
 				// Append file content to existing content
+				resource.contentPath = contentFilePath
+				resource.staticContent = resource.Content
 				resource.Content = resource.Content + string(fileContent)
 			}
 			expandedResources = append(expandedResources, resource)
 		}
 	}
-	config.Specification.Resources = expandedResources
-
-	return &config, nil
+	return tools, expandedResources, nil
 }
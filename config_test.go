@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -244,11 +245,21 @@ func TestResolveOptions(t *testing.T) {
 		cliTmpDir        string
 		cliVerbose       bool
 		cliMaxAsyncTasks int
+		cliStateDir      string
+		cliTLSCert       string
+		cliTLSKey        string
+		cliClientCA      string
+		cliTransport     string
 		setFlags         map[string]bool
 		expectedAddr     string
 		expectedTmp      string
 		expectedVerbose  bool
 		expectedMaxTasks int
+		expectedStateDir string
+		expectedTLSCert  string
+		expectedTLSKey   string
+		expectedClientCA string
+		expectedTransport string
 	}{
 		{
 			name: "Defaults only",
@@ -259,11 +270,13 @@ func TestResolveOptions(t *testing.T) {
 			cliTmpDir:        "",
 			cliVerbose:       false,
 			cliMaxAsyncTasks: 20,
+			cliTransport:     "http",
 			setFlags:         map[string]bool{},
 			expectedAddr:     "localhost:8080",
 			expectedTmp:      "",
 			expectedVerbose:  false,
 			expectedMaxTasks: 20,
+			expectedTransport: "http",
 		},
 		{
 			name: "Config overrides defaults",
@@ -273,17 +286,28 @@ func TestResolveOptions(t *testing.T) {
 					TmpDir:        "/tmp/cfg",
 					Verbose:       &vTrue,
 					MaxAsyncTasks: 50,
+					StateDir:      "/var/lib/cfg",
+					TLSCert:       "/etc/cfg/cert.pem",
+					TLSKey:        "/etc/cfg/key.pem",
+					ClientCA:      "/etc/cfg/ca.pem",
+					Transport:     "sse",
 				},
 			},
 			cliListenAddr:    "localhost:8080",
 			cliTmpDir:        "",
 			cliVerbose:       false,
 			cliMaxAsyncTasks: 20,
+			cliTransport:     "http",
 			setFlags:         map[string]bool{},
 			expectedAddr:     ":9090",
 			expectedTmp:      "/tmp/cfg",
 			expectedVerbose:  true,
 			expectedMaxTasks: 50,
+			expectedStateDir: "/var/lib/cfg",
+			expectedTLSCert:  "/etc/cfg/cert.pem",
+			expectedTLSKey:   "/etc/cfg/key.pem",
+			expectedClientCA: "/etc/cfg/ca.pem",
+			expectedTransport: "sse",
 		},
 		{
 			name: "CLI overrides config",
@@ -293,22 +317,42 @@ func TestResolveOptions(t *testing.T) {
 					TmpDir:        "/tmp/cfg",
 					Verbose:       &vTrue,
 					MaxAsyncTasks: 50,
+					StateDir:      "/var/lib/cfg",
+					TLSCert:       "/etc/cfg/cert.pem",
+					TLSKey:        "/etc/cfg/key.pem",
+					ClientCA:      "/etc/cfg/ca.pem",
+					Transport:     "sse",
 				},
 			},
 			cliListenAddr:    ":7070",
 			cliTmpDir:        "/tmp/cli",
 			cliVerbose:       false,
 			cliMaxAsyncTasks: 10,
+			cliStateDir:      "/var/lib/cli",
+			cliTLSCert:       "/etc/cli/cert.pem",
+			cliTLSKey:        "/etc/cli/key.pem",
+			cliClientCA:      "/etc/cli/ca.pem",
+			cliTransport:     "stdio",
 			setFlags: map[string]bool{
 				"listen-addr":     true,
 				"tmpdir":          true,
 				"verbose":         true,
 				"max-async-tasks": true,
+				"state-dir":       true,
+				"tls-cert":        true,
+				"tls-key":         true,
+				"client-ca":       true,
+				"transport":       true,
 			},
 			expectedAddr:     ":7070",
 			expectedTmp:      "/tmp/cli",
 			expectedVerbose:  false,
 			expectedMaxTasks: 10,
+			expectedStateDir: "/var/lib/cli",
+			expectedTLSCert:  "/etc/cli/cert.pem",
+			expectedTLSKey:   "/etc/cli/key.pem",
+			expectedClientCA: "/etc/cli/ca.pem",
+			expectedTransport: "stdio",
 		},
 		{
 			name: "Mixed override",
@@ -336,7 +380,9 @@ func TestResolveOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			addr, tmp, verb, maxTasks := resolveOptions(tt.cfg, tt.cliListenAddr, tt.cliTmpDir, tt.cliVerbose, tt.cliMaxAsyncTasks, tt.setFlags)
+			opts := resolveOptions(tt.cfg, Options{ListenAddr: tt.cliListenAddr, TmpDir: tt.cliTmpDir, Verbose: tt.cliVerbose, MaxAsyncTasks: tt.cliMaxAsyncTasks, StateDir: tt.cliStateDir,
+				TLSCert: tt.cliTLSCert, TLSKey: tt.cliTLSKey, ClientCA: tt.cliClientCA, Transport: tt.cliTransport}, tt.setFlags)
+			addr, tmp, verb, maxTasks := opts.ListenAddr, opts.TmpDir, opts.Verbose, opts.MaxAsyncTasks
 			if addr != tt.expectedAddr {
 				t.Errorf("expected addr %s, got %s", tt.expectedAddr, addr)
 			}
@@ -349,6 +395,15 @@ func TestResolveOptions(t *testing.T) {
 			if maxTasks != tt.expectedMaxTasks {
 				t.Errorf("expected maxTasks %d, got %d", tt.expectedMaxTasks, maxTasks)
 			}
+			if opts.StateDir != tt.expectedStateDir {
+				t.Errorf("expected stateDir %s, got %s", tt.expectedStateDir, opts.StateDir)
+			}
+			if opts.TLSCert != tt.expectedTLSCert || opts.TLSKey != tt.expectedTLSKey || opts.ClientCA != tt.expectedClientCA {
+				t.Errorf("expected TLS files %s, %s, %s, got %s, %s, %s", tt.expectedTLSCert, tt.expectedTLSKey, tt.expectedClientCA, opts.TLSCert, opts.TLSKey, opts.ClientCA)
+			}
+			if opts.Transport != tt.expectedTransport {
+				t.Errorf("expected transport %s, got %s", tt.expectedTransport, opts.Transport)
+			}
 		})
 	}
 }
@@ -422,8 +477,9 @@ spec:
 		t.Fatalf("LoadConfig failed: %v", err)
 	}
 
-	if len(cfg.Specification.Resources) != 2 {
-		t.Errorf("expected 2 resources, got %d", len(cfg.Specification.Resources))
+	// The directory is a single resource listing its files.
+	if len(cfg.Specification.Resources) != 1 {
+		t.Errorf("expected 1 resource, got %d", len(cfg.Specification.Resources))
 	}
 
 	resourceMap := make(map[string]ResourceItem)
@@ -431,16 +487,16 @@ spec:
 		resourceMap[res.URI] = res
 	}
 
-	if res, ok := resourceMap["simple-mcp://docs/file1.txt"]; !ok {
+	if res, ok := lookupResource(resourceMap, "simple-mcp://docs/file1.txt"); !ok {
 		t.Errorf("expected resource simple-mcp://docs/file1.txt not found")
-	} else if res.Content != "content1" {
-		t.Errorf("expected content1, got %s", res.Content)
+	} else if content, _ := getResourceContent(context.Background(), res, nil, "", false); content != "content1" {
+		t.Errorf("expected content1, got %s", content)
 	}
 
-	if res, ok := resourceMap["simple-mcp://docs/subdir/file2.txt"]; !ok {
+	if res, ok := lookupResource(resourceMap, "simple-mcp://docs/subdir/file2.txt"); !ok {
 		t.Errorf("expected resource simple-mcp://docs/subdir/file2.txt not found")
-	} else if res.Content != "content2" {
-		t.Errorf("expected content2, got %s", res.Content)
+	} else if content, _ := getResourceContent(context.Background(), res, nil, "", false); content != "content2" {
+		t.Errorf("expected content2, got %s", content)
 	}
 }
 
@@ -481,17 +537,257 @@ spec:
 		t.Fatalf("LoadConfig failed: %v", err)
 	}
 
-	found := false
-	for _, res := range cfg.Specification.Resources {
-		if res.URI == "simple-mcp://docs/info.txt" {
-			found = true
-			if res.Content != "info content" {
-				t.Errorf("expected 'info content', got '%s'", res.Content)
+	resourceMap := map[string]ResourceItem{cfg.Specification.Resources[0].URI: cfg.Specification.Resources[0]}
+	if res, ok := lookupResource(resourceMap, "simple-mcp://docs/info.txt"); !ok {
+		t.Error("relative directory resource not found")
+	} else if content, _ := getResourceContent(context.Background(), res, nil, "", false); content != "info content" {
+		t.Errorf("expected 'info content', got '%s'", content)
+	}
+}
+
+func TestLoadConfig_TypedParameters(t *testing.T) {
+	content := `
+apiVersion: v1
+kind: DynamicContextSource
+metadata:
+  name: test-mcp
+spec:
+  tools:
+    - name: Logs
+      command: journalctl -u "{{.unit}}" -n "{{.lines}}"
+      parameters:
+        - unit
+        - name: lines
+          type: integer
+          default: 10
+          maximum: 1000
+`
+	tmpfile, err := os.CreateTemp("", "config-params-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Write([]byte(content))
+	tmpfile.Close()
+
+	cfg, err := LoadConfig(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	params := cfg.Specification.Tools[0].Parameters
+	if len(params) != 2 || params[0].Name != "unit" || params[1].Type != ParamTypeInteger {
+		t.Errorf("unexpected parameters: %+v", params)
+	}
+
+	bad := strings.Replace(content, "type: integer", "type: complex", 1)
+	if err := os.WriteFile(tmpfile.Name(), []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig(tmpfile.Name())
+	if err == nil || !strings.Contains(err.Error(), "lines") {
+		t.Errorf("expected error naming the bad parameter, got: %v", err)
+	}
+}
+
+func TestLoadConfig_CommandAndArgs(t *testing.T) {
+	content := `
+apiVersion: v1
+kind: DynamicContextSource
+metadata:
+  name: test-mcp
+spec:
+  tools:
+    - name: Both
+      command: echo test
+      args: ["echo", "test"]
+`
+	tmpfile, err := os.CreateTemp("", "config-args-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Write([]byte(content))
+	tmpfile.Close()
+
+	_, err = LoadConfig(tmpfile.Name())
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("expected error about 'command' and 'args', got: %v", err)
+	}
+}
+
+func TestLoadConfig_Roles(t *testing.T) {
+	header := `
+apiVersion: v1
+kind: DynamicContextSource
+metadata:
+  name: test-mcp
+spec:
+`
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{
+			name: "Valid",
+			spec: `
+  roles:
+    - name: readonly
+    - name: admin
+      members: [alice]
+  defaultRole: readonly
+  tools:
+    - name: Reboot
+      command: reboot
+      roles: [admin]
+  resources:
+    - uri: "test://status"
+      content: ok
+      roles: [readonly, admin]
+`,
+		},
+		{
+			name: "Undefined tool role",
+			spec: `
+  roles:
+    - name: admin
+  tools:
+    - name: Reboot
+      command: reboot
+      roles: [operator]
+`,
+			wantErr: "tool Reboot refers to undefined role operator",
+		},
+		{
+			name: "Undefined default role",
+			spec: `
+  roles:
+    - name: admin
+  defaultRole: guest
+`,
+			wantErr: "defaultRole refers to undefined role guest",
+		},
+		{
+			name: "Duplicate role",
+			spec: `
+  roles:
+    - name: admin
+    - name: admin
+`,
+			wantErr: "role admin is defined more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpfile, err := os.CreateTemp("", "config-roles-*.yaml")
+			if err != nil {
+				t.Fatal(err)
 			}
-			break
-		}
+			defer os.Remove(tmpfile.Name())
+			tmpfile.Write([]byte(header + tt.spec))
+			tmpfile.Close()
+
+			cfg, err := LoadConfig(tmpfile.Name())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(cfg.Specification.Roles) != 2 || cfg.Specification.Tools[0].Roles[0] != "admin" {
+					t.Errorf("roles not parsed: %+v", cfg.Specification)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
 	}
-	if !found {
-		t.Error("relative directory resource not found")
+}
+
+func TestLoadConfig_Includes(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "tools"), 0755)
+	os.MkdirAll(filepath.Join(dir, "simple-mcp.d", "docs"), 0755)
+	writeTestFile(t, dir, "simple-mcp.d/docs/readme.txt", "drop-in content")
+
+	mainFile := writeTestFile(t, dir, "simple-mcp.yaml", `
+apiVersion: v1
+kind: DynamicContextSource
+metadata:
+  name: test-mcp
+spec:
+  include:
+    - tools/*.yaml
+  tools:
+    - name: Main
+      command: "true"
+`)
+	writeTestFile(t, dir, "tools/b.yaml", `
+tools:
+  - name: FromB
+    command: "true"
+`)
+	writeTestFile(t, dir, "tools/a.yaml", `
+tools:
+  - name: FromA
+    command: "true"
+`)
+	writeTestFile(t, dir, "simple-mcp.d/10-docs.yaml", `
+resources:
+  - uri: "test://readme"
+    contentFile: docs/readme.txt
+`)
+
+	cfg, err := LoadConfig(mainFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	var names []string
+	for _, tool := range cfg.Specification.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "Main,FromA,FromB" {
+		t.Errorf("unexpected tools: %v", names)
+	}
+	if len(cfg.Specification.Resources) != 1 || cfg.Specification.Resources[0].Content != "drop-in content" {
+		t.Errorf("expected the drop-in resource with content relative to its file, got %+v", cfg.Specification.Resources)
+	}
+	if len(cfg.Files) != 4 {
+		t.Errorf("expected 4 loaded files, got %v", cfg.Files)
+	}
+
+	// A tool defined twice names both files.
+	writeTestFile(t, dir, "simple-mcp.d/20-dup.yaml", `
+tools:
+  - name: FromA
+    command: "false"
+`)
+	_, err = LoadConfig(mainFile)
+	if err == nil || !strings.Contains(err.Error(), "tool FromA is defined in both "+filepath.Join(dir, "tools/a.yaml")+" and "+filepath.Join(dir, "simple-mcp.d/20-dup.yaml")) {
+		t.Errorf("expected duplicate tool error, got %v", err)
+	}
+	os.Remove(filepath.Join(dir, "simple-mcp.d/20-dup.yaml"))
+
+	// So does a resource.
+	writeTestFile(t, dir, "tools/c.yaml", `
+resources:
+  - uri: "test://readme"
+    content: other
+`)
+	_, err = LoadConfig(mainFile)
+	if err == nil || !strings.Contains(err.Error(), "resource test://readme is defined in both") {
+		t.Errorf("expected duplicate resource error, got %v", err)
+	}
+
+	// A missing file that is not a glob is an error.
+	missing := writeTestFile(t, dir, "missing.yaml", `
+apiVersion: v1
+kind: DynamicContextSource
+spec:
+  include: [nonexistent.yaml]
+`)
+	if _, err := LoadConfig(missing); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("expected error for missing include, got %v", err)
 	}
 }
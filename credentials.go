@@ -0,0 +1,173 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides running commands as another Unix user and group. The
+// server usually runs as root, but most read-only tools do not need to, and
+// are better run as an unprivileged user.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Capabilities needed to switch to another user and group, and to set up
+// the sandbox.
+const (
+	capSetgid   = 6
+	capSetuid   = 7
+	capSysAdmin = 21
+)
+
+// resolveCredential returns the credential of a command running as the given
+// user, group and supplementary groups, which may be names or numeric IDs. The
+// group defaults to the primary group of the user and the supplementary groups
+// to those of the user. It returns nil if none of them is set.
+func resolveCredential(userName, groupName string, supplementaryGroups []string) (*syscall.Credential, error) {
+	if userName == "" && groupName == "" && len(supplementaryGroups) == 0 {
+		return nil, nil
+	}
+
+	cred := &syscall.Credential{
+		Uid:    uint32(os.Geteuid()),
+		Gid:    uint32(os.Getegid()),
+		Groups: []uint32{},
+	}
+	var u *user.User
+	if userName != "" {
+		var err error
+		u, err = lookupUser(userName)
+		if err != nil {
+			return nil, err
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user %s has an invalid uid %q", userName, u.Uid)
+		}
+		cred.Uid = uint32(uid)
+		if gid, err := strconv.ParseUint(u.Gid, 10, 32); err == nil {
+			cred.Gid = uint32(gid)
+		} else if groupName == "" {
+			// Running in the group of the server would keep its privileges.
+			return nil, fmt.Errorf("user %s has no entry in the user database and requires a group", userName)
+		}
+	}
+
+	if groupName != "" {
+		gid, err := lookupGroup(groupName)
+		if err != nil {
+			return nil, err
+		}
+		cred.Gid = gid
+	}
+
+	groups := supplementaryGroups
+	// Users without an entry in the user database have no groups.
+	if len(groups) == 0 && u != nil && u.Username != "" {
+		groups, _ = u.GroupIds()
+	}
+	for _, name := range groups {
+		gid, err := lookupGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		cred.Groups = append(cred.Groups, gid)
+	}
+	return cred, nil
+}
+
+// lookupUser finds a user by name or ID. A numeric ID without an entry in the
+// user database is accepted as well, but has no primary group.
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+		return &user.User{Uid: name}, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown user %q", name)
+	}
+	return u, nil
+}
+
+// lookupGroup finds a group by name or ID and returns its ID.
+func lookupGroup(name string) (uint32, error) {
+	if gid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(gid), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown group %q", name)
+	}
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("group %s has an invalid gid %q", name, g.Gid)
+	}
+	return uint32(gid), nil
+}
+
+// checkPrivileges returns an error if a tool or resource of cfg runs as
+// another user or group or in a sandbox, but the server is not allowed to
+// set this up.
+func checkPrivileges(cfg *Config) error {
+	canSwitch := hasCapabilities(capSetuid, capSetgid)
+	canSandbox := hasCapabilities(capSysAdmin)
+	check := func(what string, credential *syscall.Credential, sandbox bool) error {
+		if credential != nil && !canSwitch {
+			return fmt.Errorf("%s runs as another user or group, but the server lacks the privileges to switch (it must run as root or with CAP_SETUID and CAP_SETGID)", what)
+		}
+		if sandbox && !canSandbox {
+			return fmt.Errorf("%s runs in a sandbox, but the server lacks the privileges to set it up (it must run as root or with CAP_SYS_ADMIN)", what)
+		}
+		return nil
+	}
+	for _, item := range cfg.Specification.Tools {
+		if err := check("tool "+item.Name, item.credential, item.Sandbox); err != nil {
+			return err
+		}
+	}
+	for _, item := range cfg.Specification.Resources {
+		if err := check("resource "+item.URI, item.credential, item.Sandbox); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasCapabilities reports whether the process has the given capabilities.
+func hasCapabilities(caps ...uint) bool {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return os.Geteuid() == 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		effective, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			break
+		}
+		for _, c := range caps {
+			if effective&(1<<c) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return os.Geteuid() == 0
+}
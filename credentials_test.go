@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os/user"
+	"strings"
+	"testing"
+)
+
+func TestExecuteCommand_Credential(t *testing.T) {
+	if !hasCapabilities(capSetuid, capSetgid) {
+		t.Skip("switching users requires root")
+	}
+	if _, err := user.Lookup("nobody"); err != nil {
+		t.Skip("user nobody does not exist")
+	}
+
+	cred, err := resolveCredential("nobody", "", []string{"0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	item := ContextItem{Command: "id -u; id -G", credential: cred}
+	output, _, _, err := executeCommand(context.Background(), item, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fields := strings.Fields(output)
+	if len(fields) < 2 || fields[0] != "65534" || fields[len(fields)-1] != "0" {
+		t.Errorf("expected uid 65534 with group 0, got %q", output)
+	}
+}
+
+func TestResolveCredential(t *testing.T) {
+	if cred, err := resolveCredential("", "", nil); cred != nil || err != nil {
+		t.Errorf("expected no credential, got %v, %v", cred, err)
+	}
+
+	cred, err := resolveCredential("12345", "54321", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cred.Uid != 12345 || cred.Gid != 54321 || len(cred.Groups) != 0 {
+		t.Errorf("unexpected credential %+v", cred)
+	}
+	// An unknown user has no group to fall back to.
+	if _, err := resolveCredential("12345", "", nil); err == nil || !strings.Contains(err.Error(), "requires a group") {
+		t.Errorf("expected an unknown numeric user without a group to be rejected, got %v", err)
+	}
+
+	path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Uptime
+      command: uptime
+      user: no-such-user-for-simple-mcp
+`)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), `tool Uptime: unknown user "no-such-user-for-simple-mcp"`) {
+		t.Errorf("expected unknown user to be rejected, got %v", err)
+	}
+	if problems := ValidateConfig(path); len(problems) != 1 || problems[0].Line != 7 {
+		t.Errorf("expected one problem on line 7, got %v", problems)
+	}
+}
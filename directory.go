@@ -0,0 +1,259 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides directory resources. A directory is not read when the
+// configuration is loaded. The resource at its URI lists the files it
+// currently contains, and the files are served through a resource template
+// below that URI, which reads a file when it is accessed.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// errNotInDirectory is returned for paths that are not files of a directory
+// resource, so that they are indistinguishable from missing files.
+var errNotInDirectory = errors.New("no such file in the directory")
+
+// validateDirectory checks the settings of a directory resource.
+func (r ResourceItem) validateDirectory() error {
+	for _, pattern := range append(append([]string(nil), r.Include...), r.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if r.MaxDepth < 0 {
+		return fmt.Errorf("maxDepth must not be negative")
+	}
+	if r.MaxFileBytes < 0 {
+		return fmt.Errorf("maxFileBytes must not be negative")
+	}
+	if (len(r.Include) > 0 || len(r.Exclude) > 0 || r.MaxDepth > 0 || r.MaxFileBytes > 0) && r.Directory == "" {
+		return fmt.Errorf("include, exclude, maxDepth and maxFileBytes require a directory")
+	}
+	return nil
+}
+
+// matchesFilter reports whether the file at the slash-separated path rel
+// relative to the directory is exposed. A pattern without a slash matches
+// the name of the file, a pattern with a slash its whole relative path.
+func (r ResourceItem) matchesFilter(rel string) bool {
+	if r.MaxDepth > 0 && strings.Count(rel, "/") >= r.MaxDepth {
+		return false
+	}
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			name := rel
+			if !strings.Contains(pattern, "/") {
+				name = path.Base(rel)
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(r.Include) > 0 && !matches(r.Include) {
+		return false
+	}
+	return !matches(r.Exclude)
+}
+
+// directoryFiles returns the slash-separated paths of the files of the
+// directory resource, relative to the directory and sorted.
+func (r ResourceItem) directoryFiles() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(r.dirPath, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subdirectories are left out.
+			if file != r.dirPath {
+				return nil
+			}
+			return err
+		}
+		relPath, err := filepath.Rel(r.dirPath, file)
+		if err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(relPath)
+		if d.IsDir() {
+			if r.MaxDepth > 0 && rel != "." && strings.Count(rel, "/")+1 >= r.MaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, err := r.directoryFileInfo(rel); err == nil {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// directoryFileInfo checks that rel names a file of the directory resource
+// and returns its information.
+func (r ResourceItem) directoryFileInfo(rel string) (os.FileInfo, error) {
+	if rel == "" || path.IsAbs(rel) || path.Clean(rel) != rel || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil, errNotInDirectory
+	}
+	if !r.matchesFilter(rel) {
+		return nil, errNotInDirectory
+	}
+	// Links to subdirectories are not followed, like when listing the files,
+	// as they may lead out of the directory.
+	dir := r.dirPath
+	for _, name := range strings.Split(path.Dir(rel), "/") {
+		if name == "." {
+			break
+		}
+		dir = filepath.Join(dir, name)
+		if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+			return nil, errNotInDirectory
+		}
+	}
+	// Like the files themselves, links to files are followed.
+	info, err := os.Stat(filepath.Join(r.dirPath, filepath.FromSlash(rel)))
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, errNotInDirectory
+	}
+	if r.MaxFileBytes > 0 && info.Size() > r.MaxFileBytes {
+		return nil, fmt.Errorf("the file is larger than %d bytes", r.MaxFileBytes)
+	}
+	return info, nil
+}
+
+// directoryFile returns the resource of the file at the slash-separated path
+// rel in the directory resource.
+func (r ResourceItem) directoryFile(rel string) (ResourceItem, error) {
+	if _, err := r.directoryFileInfo(rel); err != nil {
+		return ResourceItem{}, err
+	}
+	file := r
+	file.URI = directoryResourceURI(r.URI, rel)
+	file.Directory = ""
+	file.Content = ""
+	file.contentPath = filepath.Join(r.dirPath, filepath.FromSlash(rel))
+	file.dirPath = ""
+	return file, nil
+}
+
+// directoryListing returns the content of a directory resource: the URIs of
+// its files, one per line.
+func (r ResourceItem) directoryListing() (string, error) {
+	files, err := r.directoryFiles()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, file := range files {
+		b.WriteString(directoryResourceURI(r.URI, file))
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// directoryTemplateURI returns the URI template of the files of a directory
+// resource.
+func (r ResourceItem) directoryTemplateURI() string {
+	return directoryResourceURI(r.URI, "{+path}")
+}
+
+// lookupResource returns the resource at uri, which is either in resourceMap,
+// a file of one of its directory resources or matches one of its URI
+// templates.
+func lookupResource(resourceMap map[string]ResourceItem, uri string) (ResourceItem, bool) {
+	if item, ok := resourceMap[uri]; ok && item.URITemplate == "" {
+		return item, true
+	}
+	for _, item := range resourceMap {
+		if item.URITemplate != "" {
+			if expanded, err := item.expandTemplate(uri); err == nil {
+				return expanded, true
+			}
+			continue
+		}
+		if item.Directory == "" {
+			continue
+		}
+		if rel, ok := strings.CutPrefix(uri, directoryResourceURI(item.URI, "")); ok {
+			if file, err := item.directoryFile(rel); err == nil {
+				return file, true
+			}
+		}
+	}
+	return ResourceItem{}, false
+}
+
+// expandDirectories returns resourceMap with each directory resource
+// replaced by the files it currently contains. Resources with a URI template
+// cannot be listed and are left out.
+func expandDirectories(resourceMap map[string]ResourceItem) map[string]ResourceItem {
+	expanded := make(map[string]ResourceItem, len(resourceMap))
+	for uri, item := range resourceMap {
+		if item.URITemplate != "" {
+			continue
+		}
+		if item.Directory == "" {
+			expanded[uri] = item
+			continue
+		}
+		files, err := item.directoryFiles()
+		if err != nil {
+			log.Printf("ERROR: Failed to list directory for resource %s: %v", item.URI, err)
+		}
+		for _, rel := range files {
+			if file, err := item.directoryFile(rel); err == nil {
+				expanded[file.URI] = file
+			}
+		}
+	}
+	return expanded
+}
+
+// directoryTemplate builds the resource template and handler for the files
+// of a directory resource.
+func directoryTemplate(currentItem ResourceItem, auditLog *AuditLog, tmpDir string, verbose bool) server.ServerResourceTemplate {
+	options := []mcp.ResourceTemplateOption{
+		mcp.WithTemplateDescription(fmt.Sprintf("%s. Read %s for the list of files.", currentItem.Description, currentItem.URI)),
+	}
+	// Without a mimeType, the type of each file is derived from the file.
+	if currentItem.MimeType != "" {
+		options = append(options, mcp.WithTemplateMIMEType(currentItem.MimeType))
+	}
+	template := mcp.NewResourceTemplate(currentItem.directoryTemplateURI(), currentItem.Description, options...)
+
+	handler := func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		if verbose {
+			log.Printf("Handling resource read request for: %s", request.Params.URI)
+		}
+		rel, _ := strings.CutPrefix(request.Params.URI, directoryResourceURI(currentItem.URI, ""))
+		file, err := currentItem.directoryFile(rel)
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %w", request.Params.URI, err)
+		}
+		content, err := getResourceContent(ctx, file, auditLog, tmpDir, verbose)
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{resourceContents(request.Params.URI, file, content)}, nil
+	}
+
+	return server.ServerResourceTemplate{Template: template, Handler: handler}
+}
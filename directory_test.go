@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// testDirectory creates a directory resource with a few files and returns it
+// with its configuration.
+func testDirectory(t *testing.T, settings string) (ResourceItem, string) {
+	t.Helper()
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	if err := os.MkdirAll(filepath.Join(docs, "sub", "deep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, docs, "a.txt", "alpha")
+	writeTestFile(t, docs, "b.log", "bravo")
+	writeTestFile(t, docs, "sub/c.txt", "charlie")
+	writeTestFile(t, docs, "sub/deep/d.txt", "delta")
+	writeTestFile(t, docs, "big.txt", strings.Repeat("x", 100))
+	path := writeTestFile(t, dir, "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  resources:
+    - uri: "test://docs/"
+      description: "Docs"
+      directory: docs
+`+settings)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return cfg.Specification.Resources[0], docs
+}
+
+func TestDirectoryResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings string
+		files    []string
+	}{
+		{"all", "", []string{"a.txt", "b.log", "big.txt", "sub/c.txt", "sub/deep/d.txt"}},
+		{"include", "      include: [\"*.txt\"]\n", []string{"a.txt", "big.txt", "sub/c.txt", "sub/deep/d.txt"}},
+		{"exclude", "      exclude: [\"*.log\", \"sub/deep/*\"]\n", []string{"a.txt", "big.txt", "sub/c.txt"}},
+		{"maxDepth", "      maxDepth: 2\n", []string{"a.txt", "b.log", "big.txt", "sub/c.txt"}},
+		{"maxFileBytes", "      maxFileBytes: 10\n", []string{"a.txt", "b.log", "sub/c.txt", "sub/deep/d.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, _ := testDirectory(t, tt.settings)
+			files, err := item.directoryFiles()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(files, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("expected files %v, got %v", tt.files, files)
+			}
+			for _, file := range tt.files {
+				if _, ok := lookupResource(map[string]ResourceItem{item.URI: item}, "test://docs/"+file); !ok {
+					t.Errorf("expected %s to be found", file)
+				}
+			}
+		})
+	}
+}
+
+func TestDirectoryResource_Lookup(t *testing.T) {
+	item, docs := testDirectory(t, "      exclude: [\"*.log\"]\n")
+	resources := map[string]ResourceItem{item.URI: item}
+	writeTestFile(t, filepath.Dir(docs), "secret", "secret")
+	// A link to a directory outside of the tree does not lead out of it.
+	if err := os.Symlink(filepath.Dir(docs), filepath.Join(docs, "sub", "outside")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, uri := range []string{"test://docs/b.log", "test://docs/../secret", "test://docs/sub/outside/secret", "test://docs/sub/outside/docs/a.txt", "test://docs/sub", "test://docs/missing.txt", "test://docs//a.txt"} {
+		if _, ok := lookupResource(resources, uri); ok {
+			t.Errorf("expected %s not to be found", uri)
+		}
+	}
+
+	// Files are listed and read when they are accessed.
+	writeTestFile(t, docs, "new.txt", "new")
+	listing, _ := getResourceContent(context.Background(), item, nil, "", false)
+	if !strings.Contains(listing, "test://docs/new.txt\n") || strings.Contains(listing, "b.log") {
+		t.Errorf("unexpected listing %q", listing)
+	}
+	file, ok := lookupResource(resources, "test://docs/new.txt")
+	if !ok {
+		t.Fatal("expected the new file to be found")
+	}
+	writeTestFile(t, docs, "new.txt", "changed")
+	if content, _ := getResourceContent(context.Background(), file, nil, "", false); content != "changed" {
+		t.Errorf("expected the current content, got %q", content)
+	}
+}
+
+func TestDirectoryResource_Server(t *testing.T) {
+	item, docs := testDirectory(t, "")
+	cfg := &Config{Specification: Spec{Resources: []ResourceItem{item}}}
+	mcpServer := server.NewMCPServer("test", "v1", server.WithResourceCapabilities(true, true))
+	registerResources(mcpServer, cfg, nil, "", false)
+
+	readResource := func(uri string) string {
+		t.Helper()
+		message, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0", "id": 1, "method": "resources/read",
+			"params": map[string]any{"uri": uri},
+		})
+		response, _ := json.Marshal(mcpServer.HandleMessage(context.Background(), message))
+		return string(response)
+	}
+	if response := readResource("test://docs/"); !strings.Contains(response, `test://docs/sub/c.txt\n`) {
+		t.Errorf("expected the listing, got %s", response)
+	}
+	if response := readResource("test://docs/sub/c.txt"); !strings.Contains(response, `"text":"charlie"`) {
+		t.Errorf("expected the content of the file, got %s", response)
+	}
+	if response := readResource("test://docs/../simple-mcp.yaml"); !strings.Contains(response, "no such file") {
+		t.Errorf("expected an error, got %s", response)
+	}
+
+	// Subscribers of the directory learn about new files.
+	subscriptions := NewSubscriptions()
+	session := subscribedSession(t, mcpServer, subscriptions, item.URI)
+	monitor := NewResourceMonitor(mcpServer, subscriptions, nil, "", false)
+	monitor.Update(map[string]ResourceItem{item.URI: item})
+	defer monitor.Update(nil)
+	writeTestFile(t, docs, "sub/e.txt", "echo")
+	expectUpdated(t, session, item.URI, 2*time.Second)
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the environment policy of commands. By default a
+// command inherits the whole environment of the server, which may include
+// credentials handed to the service. The policy can instead pass through only
+// an allowlist of variables, set fixed values and use a safe PATH.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// safePath is the PATH of commands that do not inherit the environment.
+const safePath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// EnvironmentPolicy controls the environment of commands. It is set globally
+// in spec and can be refined for each tool and resource.
+type EnvironmentPolicy struct {
+	Inherit *bool             `yaml:"inherit,omitempty"` // Pass the whole environment of the server (default: true)
+	Allow   []string          `yaml:"allow,omitempty"`   // Variables passed through when not inheriting, may end with *
+	Set     map[string]string `yaml:"set,omitempty"`     // Variables set to fixed values
+	Path    string            `yaml:"path,omitempty"`    // PATH of the commands (default: inherited, or a safe PATH)
+}
+
+// validate checks the variable names and patterns of the policy.
+func (p *EnvironmentPolicy) validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("environment: invalid pattern %q in 'allow'", pattern)
+		}
+	}
+	if err := checkEnvNames(p.Set); err != nil {
+		return fmt.Errorf("environment: %w", err)
+	}
+	return nil
+}
+
+// merge returns the policy of an item, which refines the global policy p.
+// Settings of the item take precedence, its allowlist and values are added to
+// the global ones.
+func (p *EnvironmentPolicy) merge(item *EnvironmentPolicy) *EnvironmentPolicy {
+	if p == nil || item == nil {
+		if p == nil {
+			return item
+		}
+		return p
+	}
+	merged := &EnvironmentPolicy{
+		Inherit: p.Inherit,
+		Allow:   append(append([]string(nil), p.Allow...), item.Allow...),
+		Set:     make(map[string]string),
+		Path:    p.Path,
+	}
+	if item.Inherit != nil {
+		merged.Inherit = item.Inherit
+	}
+	if item.Path != "" {
+		merged.Path = item.Path
+	}
+	for name, value := range p.Set {
+		merged.Set[name] = value
+	}
+	for name, value := range item.Set {
+		merged.Set[name] = value
+	}
+	return merged
+}
+
+// inherits reports whether commands get the whole environment of the server.
+func (p *EnvironmentPolicy) inherits() bool {
+	return p == nil || p.Inherit == nil || *p.Inherit
+}
+
+// allowed reports whether a variable matches the allowlist.
+func (p *EnvironmentPolicy) allowed(name string) bool {
+	for _, pattern := range p.Allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// environ returns the environment of a command, in the form of os.Environ.
+func (p *EnvironmentPolicy) environ() []string {
+	if p.inherits() && (p == nil || (p.Path == "" && len(p.Set) == 0)) {
+		return os.Environ()
+	}
+
+	var env []string
+	inheritedPath := false
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if p.inherits() || p.allowed(name) {
+			env = append(env, variable)
+			inheritedPath = inheritedPath || name == "PATH"
+		}
+	}
+
+	// exec uses the last value of a variable that is set more than once.
+	switch {
+	case p.Path != "":
+		env = append(env, "PATH="+p.Path)
+	case !inheritedPath:
+		env = append(env, "PATH="+safePath)
+	}
+	names := make([]string, 0, len(p.Set))
+	for name := range p.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+p.Set[name])
+	}
+	return env
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentPolicy(t *testing.T) {
+	t.Setenv("SIMPLE_MCP_TEST_SECRET", "hunter2")
+	t.Setenv("SIMPLE_MCP_TEST_LANG", "C")
+	t.Setenv("PATH", "/opt/evil/bin:/usr/bin:/bin")
+	no := false
+
+	run := func(t *testing.T, policy *EnvironmentPolicy) string {
+		t.Helper()
+		item := ContextItem{Command: "env", Environment: policy}
+		output, _, _, err := executeCommand(context.Background(), item, map[string]interface{}{"host": "example.com"}, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return output
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		output := run(t, nil)
+		if !strings.Contains(output, "SIMPLE_MCP_TEST_SECRET=hunter2\n") || !strings.Contains(output, "PATH=/opt/evil/bin:") {
+			t.Errorf("expected the environment to be inherited, got:\n%s", output)
+		}
+	})
+
+	t.Run("Allowlist", func(t *testing.T) {
+		global := &EnvironmentPolicy{
+			Inherit: &no,
+			Allow:   []string{"SIMPLE_MCP_TEST_L*"},
+			Set:     map[string]string{"TZ": "UTC", "PAGER": "less"},
+		}
+		tool := &EnvironmentPolicy{Set: map[string]string{"PAGER": "cat"}}
+		output := run(t, global.merge(tool))
+		for _, want := range []string{"SIMPLE_MCP_TEST_LANG=C\n", "TZ=UTC\n", "PAGER=cat\n", "PATH=" + safePath + "\n", "_MCP_VAR_host=example.com\n"} {
+			if !strings.Contains(output, want) {
+				t.Errorf("expected %q in the environment, got:\n%s", want, output)
+			}
+		}
+		if strings.Contains(output, "SIMPLE_MCP_TEST_SECRET") || strings.Contains(output, "/opt/evil") {
+			t.Errorf("expected the environment not to be inherited, got:\n%s", output)
+		}
+	})
+
+	t.Run("Path", func(t *testing.T) {
+		output := run(t, &EnvironmentPolicy{Path: "/usr/bin:/bin"})
+		if !strings.Contains(output, "SIMPLE_MCP_TEST_SECRET=hunter2\n") || strings.Count(output, "\nPATH=") != 1 || !strings.Contains(output, "\nPATH=/usr/bin:/bin\n") {
+			t.Errorf("expected the inherited environment with a fixed PATH, got:\n%s", output)
+		}
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		// The program is found in the PATH of the command, not of the server.
+		serverDir, policyDir := t.TempDir(), t.TempDir()
+		for dir, output := range map[string]string{serverDir: "server", policyDir: "policy"} {
+			path := writeTestFile(t, dir, "simple-mcp-test-prog", "#!/bin/sh\necho "+output+"\n")
+			if err := os.Chmod(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Setenv("PATH", serverDir+":/usr/bin:/bin")
+		policy := &EnvironmentPolicy{Path: policyDir + ":/usr/bin:/bin"}
+		for _, item := range []ContextItem{
+			{Args: []string{"simple-mcp-test-prog"}, Environment: policy},
+			{Command: "simple-mcp-test-prog", Environment: policy},
+		} {
+			output, _, _, err := executeCommand(context.Background(), item, nil, "")
+			if err != nil || output != "policy\n" {
+				t.Errorf("expected the program of the policy PATH, got %q, %v", output, err)
+			}
+		}
+
+		item := ContextItem{Args: []string{"simple-mcp-test-prog"}, Environment: &EnvironmentPolicy{Path: "/usr/bin:/bin"}}
+		if _, _, _, err := executeCommand(context.Background(), item, nil, ""); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("expected the program not to be found, got %v", err)
+		}
+	})
+
+	t.Run("Configuration", func(t *testing.T) {
+		path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  environment:
+    inherit: false
+    allow: [LANG]
+  tools:
+    - name: Env
+      command: env
+      environment:
+        set:
+          "A=B": c
+`)
+		if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), `tool Env: environment: invalid environment variable name "A=B"`) {
+			t.Errorf("expected invalid name to be rejected, got %v", err)
+		}
+		if problems := ValidateConfig(path); len(problems) != 1 || problems[0].Line != 11 {
+			t.Errorf("expected one problem on line 11, got %v", problems)
+		}
+	})
+}
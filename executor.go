@@ -13,23 +13,78 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"syscall"
 	"text/template"
 	"time"
 )
 
+// errCommandTimeout is returned (wrapped) when a command exceeds its timeout.
+var errCommandTimeout = errors.New("command timed out")
+
+// errCommandCancelled is returned when the caller's context is cancelled
+// before the command finishes.
+var errCommandCancelled = errors.New("command was cancelled")
+
 // executeCommand renders the command template with the provided parameters
-// and executes it in a shell. It returns the combined stdout/stderr,
-// the exit code, and any Go-level error that occurred.
-func executeCommand(item ContextItem, params map[string]interface{}, workDir string) (string, int, time.Duration, error) {
+// and executes it. Tools using the `command` form run in a shell, tools using
+// the `args` form are executed directly. It returns the combined
+// stdout/stderr, truncated to the output limit of the tool, the exit code,
+// and any Go-level error that occurred. Cancelling ctx kills the command.
+func executeCommand(ctx context.Context, item ContextItem, params map[string]interface{}, workDir string) (string, int, time.Duration, error) {
+	output := newItemOutput(item)
+	defer output.Close()
+	exitCode, duration, err := streamCommand(ctx, item, params, workDir, output)
+	if errors.Is(err, errCommandTimeout) {
+		return "", exitCode, duration, err
+	}
+	return output.String(), exitCode, duration, err
+}
+
+// commandOutput is the output of a command with stdout and stderr kept apart.
+type commandOutput struct {
+	Stdout string
+	Stderr string
+}
+
+// executeCommandSplit works like executeCommand, but returns stdout and
+// stderr separately. Each of them is truncated to the output limit of the tool.
+func executeCommandSplit(ctx context.Context, item ContextItem, params map[string]interface{}, workDir string) (commandOutput, int, time.Duration, error) {
+	stdout := newItemOutput(item)
+	defer stdout.Close()
+	stderr := newItemOutput(item)
+	defer stderr.Close()
+	exitCode, duration, err := runCommand(ctx, item, params, workDir, stdout, stderr)
+	if errors.Is(err, errCommandTimeout) {
+		return commandOutput{}, exitCode, duration, err
+	}
+	return commandOutput{Stdout: stdout.String(), Stderr: stderr.String()}, exitCode, duration, err
+}
+
+// streamCommand works like executeCommand, but instead of collecting the
+// output it writes the combined stdout/stderr to out as it is produced.
+func streamCommand(ctx context.Context, item ContextItem, params map[string]interface{}, workDir string, out io.Writer) (int, time.Duration, error) {
+	return runCommand(ctx, item, params, workDir, out, out)
+}
+
+// runCommand executes the command of item, writing its stdout and stderr to
+// the given writers. An exit code listed in the successExitCodes of the tool
+// is not an error.
+func runCommand(ctx context.Context, item ContextItem, params map[string]interface{}, workDir string, stdout, stderr io.Writer) (int, time.Duration, error) {
 	startTime := time.Now()
 
 	// We separate code from data by passing parameters as environment variables.
 	envVars := make([]string, 0, len(params))
 	templateData := make(map[string]string)
+	argsData := make(map[string]string)
 
 	for key, value := range params {
 		// Sanitize the key to be a valid shell variable name
@@ -43,23 +98,35 @@ func executeCommand(item ContextItem, params map[string]interface{}, workDir str
 		}
 
 		envVarName := fmt.Sprintf("_MCP_VAR_%s", sanitizedKey.String())
-		strValue := fmt.Sprintf("%v", value)
+		strValue := formatParamValue(value)
 		envVars = append(envVars, fmt.Sprintf("%s=%s", envVarName, strValue))
 		templateData[key] = "${" + envVarName + "}"
+		// Without a shell there is nothing to expand the variable references,
+		// so argv elements are rendered with the values themselves.
+		argsData[key] = strValue
 	}
 
-	// Parse the command template
-	tmpl, err := template.New("command").Parse(item.Command)
-	if err != nil {
-		return "", -1, 0, fmt.Errorf("invalid command template in config: %w", err)
-	}
+	var argv []string
+	if len(item.Args) > 0 {
+		rendered, err := renderArgs(item.Args, argsData)
+		if err != nil {
+			return -1, 0, err
+		}
+		argv = rendered
+	} else {
+		// Parse the command template
+		tmpl, err := template.New("command").Parse(item.Command)
+		if err != nil {
+			return -1, 0, fmt.Errorf("invalid command template in config: %w", err)
+		}
 
-	// Render the command string using the variable references
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, templateData); err != nil {
-		return "", -1, 0, fmt.Errorf("failed to build command from template: %w", err)
+		// Render the command string using the variable references
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, templateData); err != nil {
+			return -1, 0, fmt.Errorf("failed to build command from template: %w", err)
+		}
+		argv = []string{"sh", "-c", buf.String()}
 	}
-	finalCommand := buf.String()
 
 	const defaultTimeout = 30
 	timeout := item.TimeoutSeconds
@@ -67,13 +134,40 @@ func executeCommand(item ContextItem, params map[string]interface{}, workDir str
 		timeout = defaultTimeout
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", finalCommand)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+
+	// Run the command in its own process group and kill the whole group on
+	// timeout or cancellation. Otherwise children of the shell would survive
+	// and keep the output pipe open.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Credential: item.credential}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	// Attach the environment allowed by the policy, the environment of the
+	// tool and our safe parameter variables
+	cmd.Env = item.Environment.environ()
+	names := make([]string, 0, len(item.Env))
+	for name := range item.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd.Env = append(cmd.Env, name+"="+item.Env[name])
+	}
+	cmd.Env = append(cmd.Env, envVars...)
+	// Output shared by all callers must not depend on the caller.
+	if principal := PrincipalFromContext(ctx); principal != nil && !item.shared {
+		cmd.Env = append(cmd.Env, "_MCP_PRINCIPAL="+principal.Name)
+	}
 
-	// Attach the current environment + our safe parameter variables
-	cmd.Env = append(os.Environ(), envVars...)
+	// The program, including the shell, is looked up in the PATH the command
+	// runs with rather than in the one of the server.
+	cmd.Path, cmd.Err = lookPath(argv[0], cmd.Env)
 
 	// Set the working directory for the command.
 	if workDir != "" {
@@ -82,7 +176,35 @@ func executeCommand(item ContextItem, params map[string]interface{}, workDir str
 		cmd.Dir = "/tmp"
 	}
 
-	output, err := cmd.CombinedOutput()
+	// Commands with limits or a sandbox are started through a helper that
+	// applies them before executing the command.
+	if item.Sandbox || !item.Limits.empty() {
+		cleanup, err := isolateCommand(cmd, item, workDir)
+		if err != nil {
+			return -1, 0, err
+		}
+		defer cleanup()
+	}
+
+	// Secrets are removed from the output before anyone can see it.
+	if redactor := newRedactor(item.secrets); len(redactor) > 0 {
+		w := redactor.writer(stdout)
+		defer w.Flush()
+		if stderr == stdout {
+			stderr = w
+		} else {
+			errW := redactor.writer(stderr)
+			defer errW.Flush()
+			stderr = errW
+		}
+		stdout = w
+	}
+
+	// Using the same writer for both streams makes exec share a single pipe,
+	// which keeps stdout and stderr interleaved in order.
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
 
 	// Default exit code to 0 on success, -1 for Go-level errors (e.g., timeout).
 	exitCode := 0
@@ -97,13 +219,85 @@ func executeCommand(item ContextItem, params map[string]interface{}, workDir str
 	duration := time.Since(startTime)
 
 	if ctx.Err() == context.DeadlineExceeded {
-		return "", -1, duration, fmt.Errorf("command timed out after %d seconds", timeout)
+		return -1, duration, fmt.Errorf("%w after %d seconds", errCommandTimeout, timeout)
 	}
 
-	if err != nil {
-		// Return the output (likely stderr) along with the error to aid debugging.
-		return string(output), exitCode, duration, fmt.Errorf("command failed: %w", err)
+	if ctx.Err() == context.Canceled {
+		return -1, duration, errCommandCancelled
+	}
+
+	if err != nil && !item.successExitCode(exitCode) {
+		return exitCode, duration, fmt.Errorf("command failed: %w", err)
+	}
+
+	return exitCode, duration, nil
+}
+
+// successExitCode reports whether exitCode means that the command of the tool
+// succeeded. Unless the tool lists its successExitCodes, only 0 does.
+func (item ContextItem) successExitCode(exitCode int) bool {
+	if exitCode < 0 {
+		return false
+	}
+	if len(item.SuccessExitCodes) == 0 {
+		return exitCode == 0
+	}
+	return slices.Contains(item.SuccessExitCodes, exitCode)
+}
+
+// lookPath finds the executable file named file in the directories of the
+// last PATH set in env, like exec.LookPath does with the PATH of the server.
+// Relative directories are skipped.
+func lookPath(file string, env []string) (string, error) {
+	if strings.Contains(file, "/") {
+		return exec.LookPath(file)
 	}
+	var pathList string
+	for _, variable := range env {
+		if value, ok := strings.CutPrefix(variable, "PATH="); ok {
+			pathList = value
+		}
+	}
+	for _, dir := range filepath.SplitList(pathList) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		path := filepath.Join(dir, file)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return file, &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+// checkExitCodes rejects exit codes a command cannot return.
+func checkExitCodes(codes []int) error {
+	for _, code := range codes {
+		if code < 0 || code > 255 {
+			return fmt.Errorf("invalid exit code %d in successExitCodes", code)
+		}
+	}
+	return nil
+}
 
-	return string(output), exitCode, duration, nil
+// renderArgs renders each element of an argv-style command as a separate
+// template. Since no shell is involved, a parameter value always ends up in
+// exactly one argv element regardless of whitespace or glob characters.
+func renderArgs(args []string, data map[string]string) ([]string, error) {
+	argv := make([]string, 0, len(args))
+	for i, arg := range args {
+		tmpl, err := template.New("arg").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template in args[%d] in config: %w", i, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to build args[%d] from template: %w", i, err)
+		}
+		argv = append(argv, buf.String())
+	}
+	if argv[0] == "" {
+		return nil, fmt.Errorf("args[0] must name the program to execute")
+	}
+	return argv, nil
 }
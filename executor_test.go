@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -16,7 +18,7 @@ func TestExecuteCommand_Templating(t *testing.T) {
 		"name": "World",
 	}
 
-	output, _, _, err := executeCommand(item, params, "")
+	output, _, _, err := executeCommand(context.Background(), item, params, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -35,7 +37,7 @@ func TestExecuteCommand_Timeout(t *testing.T) {
 	}
 
 	start := time.Now()
-	_, _, _, err := executeCommand(item, nil, "")
+	_, _, _, err := executeCommand(context.Background(), item, nil, "")
 	duration := time.Since(start)
 
 	if err == nil {
@@ -52,7 +54,7 @@ func TestExecuteCommand_InvalidTemplate(t *testing.T) {
 	item := ContextItem{
 		Command: "echo {{.missing_end_brace",
 	}
-	_, _, _, err := executeCommand(item, nil, "")
+	_, _, _, err := executeCommand(context.Background(), item, nil, "")
 	if err == nil {
 		t.Error("expected template parse error, got nil")
 	}
@@ -65,7 +67,7 @@ func TestExecuteCommand_WorkingDirectory(t *testing.T) {
 	}
 
 	// Test with a specific directory
-	output, _, _, err := executeCommand(item, nil, "/usr")
+	output, _, _, err := executeCommand(context.Background(), item, nil, "/usr")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -75,7 +77,7 @@ func TestExecuteCommand_WorkingDirectory(t *testing.T) {
 	}
 
 	// Test with the default /tmp directory
-	output, _, _, err = executeCommand(item, nil, "")
+	output, _, _, err = executeCommand(context.Background(), item, nil, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -111,7 +113,7 @@ func TestExecuteCommand_SecurityInjection(t *testing.T) {
 			params := map[string]interface{}{
 				"text": tc.input,
 			}
-			_, _, _, _ = executeCommand(item, params, "")
+			_, _, _, _ = executeCommand(context.Background(), item, params, "")
 
 			if _, err := os.Stat(tempFile); err == nil {
 				t.Errorf("Security breach: file %s was created using %s injection", tempFile, tc.name)
@@ -136,14 +138,14 @@ func TestExecuteCommand_Quoting(t *testing.T) {
 	}
 
 	// Unquoted: should split into two arguments
-	output, _, _, _ := executeCommand(itemUnquoted, params, "")
+	output, _, _, _ := executeCommand(context.Background(), itemUnquoted, params, "")
 	lines := strings.Split(strings.TrimSpace(output), "\n")
 	if len(lines) != 2 {
 		t.Errorf("expected 2 lines for unquoted space, got %d: %q", len(lines), output)
 	}
 
 	// Quoted: should stay as one argument
-	output, _, _, _ = executeCommand(itemQuoted, params, "")
+	output, _, _, _ = executeCommand(context.Background(), itemQuoted, params, "")
 	lines = strings.Split(strings.TrimSpace(output), "\n")
 	if len(lines) != 1 {
 		t.Errorf("expected 1 line for quoted space, got %d: %q", len(lines), output)
@@ -171,13 +173,13 @@ func TestExecuteCommand_Globbing(t *testing.T) {
 	}
 
 	// Unquoted: shell should expand the glob
-	output, _, _, _ := executeCommand(itemUnquoted, params, "")
+	output, _, _, _ := executeCommand(context.Background(), itemUnquoted, params, "")
 	if !strings.Contains(output, dir+"/a") || !strings.Contains(output, dir+"/b") {
 		t.Errorf("expected glob expansion for unquoted, got: %q", output)
 	}
 
 	// Quoted: shell should NOT expand the glob (passing the literal '*' to ls, which should fail or just show the literal name)
-	output, _, _, _ = executeCommand(itemQuoted, params, "")
+	output, _, _, _ = executeCommand(context.Background(), itemQuoted, params, "")
 	if strings.Contains(output, dir+"/a") && strings.Contains(output, dir+"/b") {
 		t.Errorf("did NOT expect glob expansion for quoted, but got: %q", output)
 	}
@@ -196,7 +198,7 @@ func TestExecuteCommand_Sanitization(t *testing.T) {
 		"bad-name; touch " + tempFile: "safe value",
 	}
 
-	output, _, _, err := executeCommand(item, params, "")
+	output, _, _, err := executeCommand(context.Background(), item, params, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -210,3 +212,132 @@ func TestExecuteCommand_Sanitization(t *testing.T) {
 		os.Remove(tempFile)
 	}
 }
+
+func TestExecuteCommand_Args(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "mcp-args-test")
+	defer os.RemoveAll(dir)
+	os.WriteFile(dir+"/a", []byte("a"), 0644)
+	os.WriteFile(dir+"/b", []byte("b"), 0644)
+	tempFile := dir + "/evil"
+
+	item := ContextItem{
+		Name: "PrintArgs",
+		Args: []string{"printf", "%s\n", "{{.text}}", "prefix-{{.text}}"},
+	}
+
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"Spaces", "hello world"},
+		{"Glob", dir + "/*"},
+		{"Semicolon", "dummy; touch " + tempFile},
+		{"Subshell", "$(touch " + tempFile + ")"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := map[string]interface{}{
+				"text": tc.input,
+			}
+			output, _, _, err := executeCommand(context.Background(), item, params, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := tc.input + "\nprefix-" + tc.input + "\n"
+			if output != expected {
+				t.Errorf("expected each parameter to stay a single argument %q, got %q", expected, output)
+			}
+			if _, err := os.Stat(tempFile); err == nil {
+				t.Errorf("Security breach: file %s was created using %s injection", tempFile, tc.name)
+				os.Remove(tempFile)
+			}
+		})
+	}
+}
+
+func TestExecuteCommand_Principal(t *testing.T) {
+	item := ContextItem{Command: "echo -n $_MCP_PRINCIPAL"}
+	ctx := withPrincipal(context.Background(), &Principal{Name: "alice", Method: "basic"})
+	output, _, _, err := executeCommand(ctx, item, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "alice" {
+		t.Errorf("expected principal in environment, got %q", output)
+	}
+}
+
+func TestExecuteCommand_ArgsErrors(t *testing.T) {
+	item := ContextItem{Args: []string{"echo", "{{.missing_end_brace"}}
+	if _, _, _, err := executeCommand(context.Background(), item, nil, ""); err == nil {
+		t.Error("expected template parse error, got nil")
+	}
+
+	item = ContextItem{Args: []string{"{{.program}}"}}
+	if _, _, _, err := executeCommand(context.Background(), item, map[string]interface{}{"program": ""}, ""); err == nil {
+		t.Error("expected error for empty program name, got nil")
+	}
+}
+
+func TestStreamCommand_Incremental(t *testing.T) {
+	item := ContextItem{
+		Name:    "Stream",
+		Command: "echo first; sleep 1; echo second >&2",
+	}
+	out := &TaskOutput{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, _, err := streamCommand(context.Background(), item, nil, "", out); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(900 * time.Millisecond)
+	for out.Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := out.String(); got != "first\n" {
+		t.Errorf("expected partial output while running, got %q", got)
+	}
+
+	<-done
+	if got := out.String(); got != "first\nsecond\n" {
+		t.Errorf("expected stdout and stderr in order, got %q", got)
+	}
+}
+
+func TestExecuteCommand_Cancel(t *testing.T) {
+	marker := "/tmp/simple-mcp-test-cancel"
+	os.Remove(marker)
+	defer os.Remove(marker)
+
+	// The background child must be killed together with the shell.
+	item := ContextItem{
+		Name:           "Cancellable",
+		Command:        "echo started; (sleep 2; touch " + marker + ") & sleep 10",
+		TimeoutSeconds: 30,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+
+	start := time.Now()
+	output, _, _, err := executeCommand(ctx, item, nil, "")
+	if !errors.Is(err, errCommandCancelled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("cancellation took too long (%v)", time.Since(start))
+	}
+	if strings.TrimSpace(output) != "started" {
+		t.Errorf("expected partial output, got %q", output)
+	}
+
+	time.Sleep(2 * time.Second)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("child process survived cancellation")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides resource limits for commands. Limits are applied as
+// rlimits by the helper that starts the command and, where the server can
+// manage cgroup v2, with a transient cgroup for each invocation, which also
+// covers the children of the command.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ResourceLimits restricts the resources a command may use. Zero means no
+// limit. The limits can be set globally in spec and for each tool and resource.
+type ResourceLimits struct {
+	MemoryMB   int `yaml:"memoryMB,omitempty"`   // Memory of the command and its children
+	CPUSeconds int `yaml:"cpuSeconds,omitempty"` // CPU time of each process
+	OpenFiles  int `yaml:"openFiles,omitempty"`  // Open files of each process
+	Processes  int `yaml:"processes,omitempty"`  // Processes of the command and its children
+	FileSizeMB int `yaml:"fileSizeMB,omitempty"` // Size of each file the command writes
+}
+
+// validate rejects negative limits.
+func (l *ResourceLimits) validate() error {
+	if l == nil {
+		return nil
+	}
+	if l.MemoryMB < 0 || l.CPUSeconds < 0 || l.OpenFiles < 0 || l.Processes < 0 || l.FileSizeMB < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// merge returns the limits of an item, which override the global limits l.
+func (l *ResourceLimits) merge(item *ResourceLimits) *ResourceLimits {
+	if l == nil || item == nil {
+		if l == nil {
+			return item
+		}
+		return l
+	}
+	merged := *l
+	if item.MemoryMB != 0 {
+		merged.MemoryMB = item.MemoryMB
+	}
+	if item.CPUSeconds != 0 {
+		merged.CPUSeconds = item.CPUSeconds
+	}
+	if item.OpenFiles != 0 {
+		merged.OpenFiles = item.OpenFiles
+	}
+	if item.Processes != 0 {
+		merged.Processes = item.Processes
+	}
+	if item.FileSizeMB != 0 {
+		merged.FileSizeMB = item.FileSizeMB
+	}
+	return &merged
+}
+
+// empty reports whether no limit is set.
+func (l *ResourceLimits) empty() bool {
+	return l == nil || *l == ResourceLimits{}
+}
+
+// rlimit is a resource limit set by the helper before it executes the command.
+type rlimit struct {
+	Resource int    `json:"resource"`
+	Value    uint64 `json:"value"`
+}
+
+// rlimits returns the rlimits implementing l. Memory and processes are
+// limited by the cgroup instead, if there is one.
+func (l *ResourceLimits) rlimits(cgroup bool) []rlimit {
+	if l == nil {
+		return nil
+	}
+	var limits []rlimit
+	if l.MemoryMB > 0 && !cgroup {
+		limits = append(limits, rlimit{syscall.RLIMIT_AS, uint64(l.MemoryMB) << 20})
+	}
+	if l.CPUSeconds > 0 {
+		limits = append(limits, rlimit{syscall.RLIMIT_CPU, uint64(l.CPUSeconds)})
+	}
+	if l.OpenFiles > 0 {
+		limits = append(limits, rlimit{syscall.RLIMIT_NOFILE, uint64(l.OpenFiles)})
+	}
+	if l.Processes > 0 && !cgroup {
+		limits = append(limits, rlimit{rlimitNproc, uint64(l.Processes)})
+	}
+	if l.FileSizeMB > 0 {
+		limits = append(limits, rlimit{syscall.RLIMIT_FSIZE, uint64(l.FileSizeMB) << 20})
+	}
+	return limits
+}
+
+// rlimitNproc is RLIMIT_NPROC, which the syscall package does not define.
+const rlimitNproc = 6
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// commandCgroups returns the cgroup below which each command gets a cgroup
+// of its own, or "" if the server cannot manage cgroup v2. It is set up on
+// first use.
+var commandCgroups = sync.OnceValue(func() string {
+	parent, err := setupCgroups()
+	if err != nil {
+		log.Printf("WARNING: Memory and process limits are applied with rlimits only, cgroup v2 is not available: %v", err)
+		return ""
+	}
+	log.Printf("Commands with limits run in transient cgroups below %s", parent)
+	return parent
+})
+
+// cgroupCounter makes the names of transient cgroups unique.
+var cgroupCounter atomic.Uint64
+
+// setupCgroups prepares the cgroup of the server for the cgroups of the
+// commands. A cgroup with controllers enabled for its children may not hold
+// processes itself, so the server moves to a leaf cgroup of its own. This
+// needs a delegated cgroup, e.g. Delegate=yes in the systemd unit.
+func setupCgroups() (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", errors.New("no unified cgroup hierarchy")
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	var own string
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			own = filepath.Join(cgroupRoot, path)
+		}
+	}
+	if own == "" {
+		return "", errors.New("the server is not in a cgroup v2")
+	}
+
+	leaf := filepath.Join(own, "server")
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(own, "cgroup.subtree_control"), []byte("+memory +pids"), 0); err != nil {
+		return "", err
+	}
+	return own, nil
+}
+
+// commandCgroup is the transient cgroup of a single command.
+type commandCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// newCommandCgroup creates a cgroup with the memory and process limits of l
+// below parent.
+func newCommandCgroup(parent string, l *ResourceLimits) (*commandCgroup, error) {
+	path := filepath.Join(parent, fmt.Sprintf("cmd-%d", cgroupCounter.Add(1)))
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, err
+	}
+	cg := &commandCgroup{path: path}
+	if l.MemoryMB > 0 {
+		if err := os.WriteFile(filepath.Join(path, "memory.max"), []byte(strconv.Itoa(l.MemoryMB<<20)), 0); err != nil {
+			cg.remove()
+			return nil, err
+		}
+		// Without swap, the limit is a hard one. The file is missing if
+		// the kernel has no swap support.
+		os.WriteFile(filepath.Join(path, "memory.swap.max"), []byte("0"), 0)
+	}
+	if l.Processes > 0 {
+		if err := os.WriteFile(filepath.Join(path, "pids.max"), []byte(strconv.Itoa(l.Processes)), 0); err != nil {
+			cg.remove()
+			return nil, err
+		}
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		cg.remove()
+		return nil, err
+	}
+	cg.dir = dir
+	return cg, nil
+}
+
+// remove kills the processes left in the cgroup and removes it.
+func (cg *commandCgroup) remove() {
+	if cg.dir != nil {
+		cg.dir.Close()
+	}
+	os.WriteFile(filepath.Join(cg.path, "cgroup.kill"), []byte("1"), 0)
+	// The cgroup can only be removed once the killed processes are gone.
+	for i := 0; i < 50; i++ {
+		if err := os.Remove(cg.path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	log.Printf("WARNING: Could not remove cgroup %s", cg.path)
+}
@@ -12,14 +12,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -33,29 +37,60 @@ func countLines(s string) int {
 	return strings.Count(s, "\n") + 1
 }
 
-func resolveOptions(cfg *Config, cliListenAddr string, cliTmpDir string, cliVerbose bool, cliMaxAsyncTasks int, setFlags map[string]bool) (string, string, bool, int) {
+// Options holds the server settings that can be given both on the command
+// line and in the configuration file.
+type Options struct {
+	ListenAddr    string
+	TmpDir        string
+	Verbose       bool
+	MaxAsyncTasks int
+	StateDir      string
+	TLSCert       string
+	TLSKey        string
+	ClientCA      string
+	Transport     string
+}
+
+func resolveOptions(cfg *Config, cli Options, setFlags map[string]bool) Options {
 	// Precedence: Command-line flag > YAML config > Default.
-	finalListenAddr := cliListenAddr
+	opts := cli
 	if !setFlags["listen-addr"] && cfg.Specification.ListenAddr != "" {
-		finalListenAddr = cfg.Specification.ListenAddr
+		opts.ListenAddr = cfg.Specification.ListenAddr
 	}
 
-	finalTmpDir := cliTmpDir
 	if !setFlags["tmpdir"] && cfg.Specification.TmpDir != "" {
-		finalTmpDir = cfg.Specification.TmpDir
+		opts.TmpDir = cfg.Specification.TmpDir
 	}
 
-	finalVerbose := cliVerbose
 	if !setFlags["verbose"] && cfg.Specification.Verbose != nil {
-		finalVerbose = *cfg.Specification.Verbose
+		opts.Verbose = *cfg.Specification.Verbose
 	}
 
-	finalMaxAsyncTasks := cliMaxAsyncTasks
 	if !setFlags["max-async-tasks"] && cfg.Specification.MaxAsyncTasks != 0 {
-		finalMaxAsyncTasks = cfg.Specification.MaxAsyncTasks
+		opts.MaxAsyncTasks = cfg.Specification.MaxAsyncTasks
+	}
+
+	if !setFlags["state-dir"] && cfg.Specification.StateDir != "" {
+		opts.StateDir = cfg.Specification.StateDir
+	}
+
+	if !setFlags["tls-cert"] && cfg.Specification.TLSCert != "" {
+		opts.TLSCert = cfg.Specification.TLSCert
+	}
+
+	if !setFlags["tls-key"] && cfg.Specification.TLSKey != "" {
+		opts.TLSKey = cfg.Specification.TLSKey
+	}
+
+	if !setFlags["client-ca"] && cfg.Specification.ClientCA != "" {
+		opts.ClientCA = cfg.Specification.ClientCA
 	}
 
-	return finalListenAddr, finalTmpDir, finalVerbose, finalMaxAsyncTasks
+	if !setFlags["transport"] && cfg.Specification.Transport != "" {
+		opts.Transport = cfg.Specification.Transport
+	}
+
+	return opts
 }
 
 func main() {
@@ -64,13 +99,38 @@ func main() {
 	tmpDir := flag.String("tmpdir", "", "Path to a directory for scratch space.")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging of MCP protocol messages.")
 	maxAsyncTasks := flag.Int("max-async-tasks", 20, "Maximum number of asynchronous tasks to keep in memory.")
+	stateDir := flag.String("state-dir", "", "Path to a directory for persisting async tasks across restarts.")
+	tlsCert := flag.String("tls-cert", "", "Path to the TLS certificate. Enables HTTPS.")
+	tlsKey := flag.String("tls-key", "", "Path to the TLS private key.")
+	clientCA := flag.String("client-ca", "", "Path to a CA bundle for verifying client certificates. Enables mutual TLS.")
+	transport := flag.String("transport", "http", "Transport to serve MCP over: http (Streamable HTTP), sse or stdio.")
+	printSchema := flag.Bool("print-schema", false, "Print the JSON Schema of the configuration file and exit.")
 	flag.Parse()
 
+	if *printSchema {
+		schema, err := configSchema()
+		if err != nil {
+			log.Fatalf("ERROR: Failed to generate the configuration schema: %v", err)
+		}
+		fmt.Println(string(schema))
+		return
+	}
+
+	// "simple-mcp validate" checks the configuration and exits. Flags may
+	// follow the subcommand.
+	if flag.Arg(0) == "validate" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+		os.Exit(runValidate(*configFile))
+	}
+
 	cfg, err := LoadConfig(*configFile)
 	if err != nil {
 		log.Fatalf("ERROR: Error loading configuration: %v", err)
 	}
 	log.Printf("Configuration loaded successfully from %s", *configFile)
+	if err := checkPrivileges(cfg); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
 
 	// Determine which flags were explicitly set by the user on the command line.
 	setFlags := make(map[string]bool)
@@ -78,7 +138,37 @@ func main() {
 		setFlags[f.Name] = true
 	})
 
-	finalListenAddr, finalTmpDir, finalVerbose, finalMaxAsyncTasks := resolveOptions(cfg, *listenAddr, *tmpDir, *verbose, *maxAsyncTasks, setFlags)
+	cliOpts := Options{
+		ListenAddr:    *listenAddr,
+		TmpDir:        *tmpDir,
+		Verbose:       *verbose,
+		MaxAsyncTasks: *maxAsyncTasks,
+		StateDir:      *stateDir,
+		TLSCert:       *tlsCert,
+		TLSKey:        *tlsKey,
+		ClientCA:      *clientCA,
+		Transport:     *transport,
+	}
+	opts := resolveOptions(cfg, cliOpts, setFlags)
+	resolvedOpts := opts
+	finalListenAddr, finalTmpDir, finalVerbose := opts.ListenAddr, opts.TmpDir, opts.Verbose
+
+	// In stdio mode stdout carries the JSON-RPC stream. Keep it for the
+	// transport and send everything else, including stray prints, to stderr.
+	stdout := os.Stdout
+	switch opts.Transport {
+	case "stdio":
+		os.Stdout = os.Stderr
+		log.SetOutput(os.Stderr)
+		if cfg.Specification.Auth != nil || opts.TLSCert != "" || opts.ClientCA != "" {
+			log.Printf("WARNING: Authentication and TLS settings are ignored with the stdio transport.")
+			cfg.Specification.Auth = nil
+			opts.TLSCert, opts.TLSKey, opts.ClientCA = "", "", ""
+		}
+	case "http", "sse":
+	default:
+		log.Fatalf("ERROR: Unknown transport %q, expected http, sse or stdio", opts.Transport)
+	}
 
 	if finalTmpDir != "" {
 		// Verify the directory exists and is writable first.
@@ -100,43 +190,203 @@ func main() {
 		log.Printf("Scratch space enabled at: %s", finalTmpDir)
 	}
 
-	taskStore := NewTaskStore(finalMaxAsyncTasks)
-	log.Printf("Task store initialized with limit: %d", finalMaxAsyncTasks)
+	var certs *certReloader
+	if opts.TLSCert != "" || opts.TLSKey != "" || opts.ClientCA != "" {
+		if opts.TLSCert == "" || opts.TLSKey == "" {
+			log.Fatalf("ERROR: Both a TLS certificate and a key are required to enable TLS")
+		}
+		certs, err = newCertReloader(opts.TLSCert, opts.TLSKey, opts.ClientCA)
+		if err != nil {
+			log.Fatalf("ERROR: Could not set up TLS: %v", err)
+		}
+		if opts.ClientCA != "" {
+			log.Printf("TLS enabled, client certificates are required.")
+		} else {
+			log.Printf("TLS enabled.")
+		}
+	}
 
-	// Pre-cache resource definitions for efficient lookup by the GetResource tool.
-	resourceMap := make(map[string]ResourceItem)
-	for _, item := range cfg.Specification.Resources {
-		resourceMap[item.URI] = item
+	var authenticator *Authenticator
+	if cfg.Specification.Auth != nil {
+		authenticator, err = NewAuthenticator(cfg.Specification.Auth)
+		if err != nil {
+			log.Fatalf("ERROR: Could not set up authentication: %v", err)
+		}
+		log.Printf("Authentication enabled for the HTTP endpoint.")
+	}
+
+	var auditLog *AuditLog
+	if cfg.Specification.Audit != nil {
+		auditLog, err = NewAuditLog(cfg.Specification.Audit)
+		if err != nil {
+			log.Fatalf("ERROR: Could not open audit log: %v", err)
+		}
+		defer auditLog.Close()
+		log.Printf("Audit log enabled: %s", cfg.Specification.Audit.Path)
 	}
-	log.Printf("Cached %d resource definitions.", len(resourceMap))
 
-	mcpServer := server.NewMCPServer(
-		cfg.Metadata.Name,
-		cfg.APIVersion,
-		server.WithToolCapabilities(false),
+	var taskStore TaskStore
+	if opts.StateDir != "" {
+		taskStore, err = NewJournalTaskStore(opts.StateDir, opts.MaxAsyncTasks)
+		if err != nil {
+			log.Fatalf("ERROR: Could not open task store: %v", err)
+		}
+		log.Printf("Task store initialized with limit: %d, persisted in %s (%d tasks restored)", opts.MaxAsyncTasks, opts.StateDir, len(taskStore.ListTasks()))
+	} else {
+		taskStore = NewTaskStore(opts.MaxAsyncTasks)
+		log.Printf("Task store initialized with limit: %d", opts.MaxAsyncTasks)
+	}
+
+	// Keep the tools, resources and access policy in a registry, so they can
+	// be replaced when the configuration is reloaded.
+	registry := NewRegistry(cfg, taskStore)
+	log.Printf("Cached %d resource definitions.", len(registry.Resources()))
+
+	// Track resource subscriptions and forget them when a session ends. Only
+	// callers allowed to read a resource may subscribe to it.
+	subscriptions := NewSubscriptions()
+	subscriptions.SetPolicy(registry.Policy)
+	hooks := &server.Hooks{}
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		subscriptions.RemoveSession(session.SessionID())
+	})
+	hooks.AddOnRequestInitialization(subscriptions.RequestHook)
+
+	// Restrict tools and resources to the roles allowed to use them.
+	if registry.Policy() != nil {
+		log.Printf("Role-based access control enabled with %d roles.", len(cfg.Specification.Roles))
+	}
+
+	serverOpts := []server.ServerOption{
+		server.WithToolCapabilities(true),           // Advertise tools/list_changed for reloads
 		server.WithRecovery(),                       // Gracefully handle panics in handlers
 		server.WithResourceCapabilities(true, true), // Advertise resource support
-	)
+		server.WithHooks(hooks),
+	}
+	serverOpts = append(serverOpts, registry.ServerOptions()...)
+	mcpServer := server.NewMCPServer(cfg.Metadata.Name, cfg.APIVersion, serverOpts...)
 	log.Printf("MCP Server %s with API %s created.", cfg.Metadata.Name, cfg.APIVersion)
 
-	registerBuiltinTools(mcpServer, taskStore, resourceMap, finalTmpDir, finalVerbose)
-	registerConfigTools(mcpServer, cfg, taskStore, finalTmpDir, finalVerbose)
-	registerResources(mcpServer, cfg, finalTmpDir, finalVerbose)
+	registerBuiltinTools(mcpServer, taskStore, registry, auditLog, finalTmpDir, finalVerbose)
+	// Make the tasks restored from the state directory readable again.
+	for _, task := range taskStore.ListTasks() {
+		registerTaskResource(mcpServer, taskStore, task.ID, task.ToolName)
+	}
+	registerConfigTools(mcpServer, cfg, taskStore, subscriptions, auditLog, finalTmpDir, finalVerbose)
+	registerResources(mcpServer, cfg, auditLog, finalTmpDir, finalVerbose)
+
+	// Notify subscribers when the content of a resource changes.
+	monitor := NewResourceMonitor(mcpServer, subscriptions, auditLog, finalTmpDir, finalVerbose)
+	monitor.Update(registry.Resources())
+
+	// Apply changes of the configuration file to the running server.
+	reloader := &configReloader{
+		path:     *configFile,
+		server:   mcpServer,
+		registry: registry,
+		opts:     resolvedOpts,
+		resolve: func(cfg *Config) Options {
+			return resolveOptions(cfg, cliOpts, setFlags)
+		},
+		taskStore:     taskStore,
+		subscriptions: subscriptions,
+		monitor:       monitor,
+		authenticator: authenticator,
+		auditLog:      auditLog,
+		tmpDir:        finalTmpDir,
+		verbose:       finalVerbose,
+	}
+	if err := reloader.watch(cfg); err != nil {
+		log.Printf("WARNING: Could not watch %s for changes, reload with SIGHUP instead: %v", *configFile, err)
+	}
+
+	// Reload the configuration and the TLS certificates (e.g. after they
+	// were renewed) on SIGHUP.
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			reloader.reloadAndLog()
+			if certs == nil {
+				continue
+			}
+			if err := certs.reload(); err != nil {
+				log.Printf("ERROR: Failed to reload TLS certificates, keeping the old ones: %v", err)
+			} else {
+				log.Printf("TLS certificates reloaded.")
+			}
+		}
+	}()
 
 	if finalTmpDir != "" {
-		registerScratchTools(mcpServer, resourceMap, finalTmpDir, finalVerbose)
+		registerScratchTools(mcpServer, registry, auditLog, finalTmpDir, finalVerbose)
 	}
 
-	log.Printf("Creating Streamable HTTP server...")
-	httpOpts := []server.StreamableHTTPOption{}
-	httpServer := server.NewStreamableHTTPServer(mcpServer, httpOpts...)
+	if opts.Transport == "stdio" {
+		log.Printf("MCP server starting on stdio ...")
+		if err := server.NewStdioServer(mcpServer).Listen(context.Background(), subscriptions.StdioReader(os.Stdin), stdout); err != nil {
+			log.Fatalf("ERROR: stdio server failed: %v", err)
+		}
+		return
+	}
+
+	mux := http.NewServeMux()
+	httpSrv := &http.Server{Addr: finalListenAddr, Handler: mux}
+
+	// wrapHandler adds the client certificate and authentication checks in
+	// front of an MCP transport handler.
+	wrapHandler := func(handler http.Handler) http.Handler {
+		if authenticator != nil {
+			handler = authenticator.Middleware(handler)
+		}
+		if certs != nil {
+			handler = clientCertMiddleware(handler)
+		}
+		return handler
+	}
+
+	var endpoint string
+	if opts.Transport == "sse" {
+		log.Printf("Creating SSE server...")
+		sseServer := server.NewSSEServer(mcpServer, server.WithHTTPServer(httpSrv))
+		mux.Handle("/", wrapHandler(subscriptions.SSEMiddleware(sseServer)))
+		endpoint = "/sse"
+	} else {
+		log.Printf("Creating Streamable HTTP server...")
+		httpServer := server.NewStreamableHTTPServer(mcpServer, server.WithStreamableHTTPServer(httpSrv))
+		mux.Handle("/mcp", wrapHandler(subscriptions.Middleware(httpServer)))
+		endpoint = "/mcp"
+	}
 
-	log.Printf("MCP server starting, listening on %s/mcp ...", finalListenAddr)
-	if err := httpServer.Start(finalListenAddr); err != nil {
+	if certs != nil {
+		// The certificates come from the reloader, not from files given here.
+		httpSrv.TLSConfig = certs.tlsConfig()
+		log.Printf("MCP server starting, listening on https://%s%s ...", finalListenAddr, endpoint)
+		err = httpSrv.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("MCP server starting, listening on %s%s ...", finalListenAddr, endpoint)
+		err = httpSrv.ListenAndServe()
+	}
+	if err != nil {
 		log.Fatalf("ERROR: Could not start HTTP server: %v", err)
 	}
 }
 
+// runValidate prints all problems found in the configuration and returns the
+// exit code: 0 if the configuration is valid, 1 otherwise.
+func runValidate(configFile string) int {
+	problems := ValidateConfig(configFile)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d problem(s) found\n", configFile, len(problems))
+		return 1
+	}
+	fmt.Printf("%s: configuration is valid\n", configFile)
+	return 0
+}
+
 func checkTmpDir(path string) error {
 	info, err := os.Stat(path)
 	if err != nil {
@@ -159,10 +409,10 @@ func checkTmpDir(path string) error {
 	return nil
 }
 
-
 // registerBuiltinTools adds the core infrastructure tools required for
-// mcphost compatibility and async task management.
-func registerBuiltinTools(mcpServer *server.MCPServer, taskStore *TaskStore, resourceMap map[string]ResourceItem, tmpDir string, verbose bool) {
+// mcphost compatibility and async task management. The resources and tasks
+// they show are limited to those the caller may access.
+func registerBuiltinTools(mcpServer *server.MCPServer, taskStore TaskStore, registry *Registry, auditLog *AuditLog, tmpDir string, verbose bool) {
 	// Helps the LLM recover context if it forgets a task ID.
 	listTasksTool := mcp.NewTool(
 		"ListPendingTasks",
@@ -172,7 +422,12 @@ func registerBuiltinTools(mcpServer *server.MCPServer, taskStore *TaskStore, res
 		if verbose {
 			log.Printf("Handling ListPendingTasks request.")
 		}
-		activeTasks := taskStore.ListActiveTasks()
+		var activeTasks []*AsyncTask
+		for _, task := range taskStore.ListActiveTasks() {
+			if registry.Policy().CanUseTool(ctx, task.ToolName) {
+				activeTasks = append(activeTasks, task)
+			}
+		}
 		if len(activeTasks) == 0 {
 			return mcp.NewToolResultText("No active (pending or running) tasks found."), nil
 		}
@@ -209,7 +464,7 @@ func registerBuiltinTools(mcpServer *server.MCPServer, taskStore *TaskStore, res
 		}
 
 		task, ok := taskStore.Get(taskID)
-		if !ok {
+		if !ok || !registry.Policy().CanUseTool(ctx, task.ToolName) {
 			log.Printf("TaskStatus request for non-existent ID: %s", taskID)
 			return mcp.NewToolResultText(fmt.Sprintf("Status: not_found\nMessage: No task found with ID: %s", taskID)), nil
 		}
@@ -219,6 +474,44 @@ func registerBuiltinTools(mcpServer *server.MCPServer, taskStore *TaskStore, res
 	})
 	log.Printf("Registered built-in tool: %s", taskStatusTool.Name)
 
+	// Stops a running async task by killing its whole process group.
+	cancelTaskTool := mcp.NewTool(
+		"CancelTask",
+		mcp.WithDescription("Cancels a running async task. The command and all its child processes are killed and the output produced so far is kept."),
+		mcp.WithString(
+			"taskID",
+			mcp.Required(),
+			mcp.Description("The Task ID (e.g., task-...) or full Task URI (e.g., simple-mcp://tasks/...)"),
+		),
+	)
+	mcpServer.AddTool(cancelTaskTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		taskID, _ := request.RequireString("taskID")
+		taskID = strings.TrimPrefix(taskID, "simple-mcp://tasks/")
+
+		if verbose {
+			log.Printf("Handling CancelTask request for taskID: %s", taskID)
+		}
+
+		if !registry.Policy().CanAccessTask(ctx, taskID) {
+			return mcp.NewToolResultError(fmt.Sprintf("No task found with ID: %s", taskID)), nil
+		}
+		task, err := taskStore.Cancel(taskID)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		log.Printf("Cancelling async job %s", task.ID)
+
+		// Killing the process group is quick, so wait for the job to record
+		// its final state before reporting back.
+		select {
+		case <-task.Done():
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+		}
+		return mcp.NewToolResultText(task.FormatStatus()), nil
+	})
+	log.Printf("Registered built-in tool: %s", cancelTaskTool.Name)
+
 	// Provides a discoverable list of system context resources.
 	listResourcesTool := mcp.NewTool(
 		"ListResources",
@@ -228,9 +521,10 @@ func registerBuiltinTools(mcpServer *server.MCPServer, taskStore *TaskStore, res
 		if verbose {
 			log.Printf("Handling ListResources request.")
 		}
+		visible := registry.visibleResources(ctx)
 		var b strings.Builder
-		b.WriteString(fmt.Sprintf("Found %d resources:\n\n", len(resourceMap)))
-		for uri, item := range resourceMap {
+		b.WriteString(fmt.Sprintf("Found %d resources:\n\n", len(visible)))
+		for uri, item := range visible {
 			b.WriteString(fmt.Sprintf("URI: %s\nDescription: %s\n\n", uri, item.Description))
 		}
 		return mcp.NewToolResultText(b.String()), nil
@@ -253,18 +547,22 @@ func registerBuiltinTools(mcpServer *server.MCPServer, taskStore *TaskStore, res
 			log.Printf("Handling GetResource request for: %s", resourceURI)
 		}
 
-		item, ok := resourceMap[resourceURI]
+		item, ok := lookupResource(registry.visibleResources(ctx), resourceURI)
 		if !ok {
 			return mcp.NewToolResultError(fmt.Sprintf("Resource not found: %s. Call ListResources to see available URIs.", resourceURI)), nil
 		}
 
-		content, err := getResourceContent(item, tmpDir, verbose)
+		content, err := getResourceContent(ctx, item, auditLog, tmpDir, verbose)
 		if err != nil {
-			// getResourceContent should not return errors, but we handle it just in case.
-			log.Printf("ERROR: Unexpected error getting resource content for %s: %v", resourceURI, err)
-			return mcp.NewToolResultError(fmt.Sprintf("Unexpected error getting resource content for %s: %v", resourceURI, err)), nil
+			// The caller stopped waiting for the content.
+			log.Printf("ERROR: Failed to get resource content for %s: %v", resourceURI, err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get resource content for %s: %v", resourceURI, err)), nil
 		}
 
+		// Binary content cannot be returned as text.
+		if blob, ok := resourceContents(resourceURI, item, content).(mcp.BlobResourceContents); ok {
+			return mcp.NewToolResultResource(fmt.Sprintf("Resource %s has %d bytes of %s data.", resourceURI, len(content), blob.MIMEType), blob), nil
+		}
 		return mcp.NewToolResultText(content), nil
 	})
 	log.Printf("Registered built-in tool: %s", getResourceTool.Name)
@@ -285,7 +583,7 @@ func registerBuiltinTools(mcpServer *server.MCPServer, taskStore *TaskStore, res
 			log.Printf("Handling SearchResources request with query: %s", query)
 		}
 
-		result, err := searchResources(resourceMap, query)
+		result, err := searchResources(registry.visibleResources(ctx), query)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -325,24 +623,64 @@ func searchResources(resourceMap map[string]ResourceItem, query string) (string,
 }
 
 // getResourceContent generates the content for a given resource, handling static content,
-// dynamic command execution, and the combination of both.
-func getResourceContent(item ResourceItem, tmpDir string, verbose bool) (string, error) {
+// dynamic command execution, and the combination of both. The content of
+// dynamic resources with an interval is cached for that long. Failures of the
+// command are part of the content; an error is only returned if ctx is done
+// while waiting for the cached content.
+func getResourceContent(ctx context.Context, item ResourceItem, auditLog *AuditLog, tmpDir string, verbose bool) (string, error) {
+	load := func(ctx context.Context) (string, bool) {
+		return evaluateResource(ctx, item, auditLog, tmpDir, verbose)
+	}
+	if item.IntervalSeconds > 0 && (item.Command != "" || len(item.Args) > 0) {
+		return resourceCache.Get(ctx, item, load)
+	}
+	content, _ := load(ctx)
+	return content, nil
+}
+
+// evaluateResource runs the command of a resource and returns its content. It
+// reports whether the command succeeded.
+func evaluateResource(ctx context.Context, item ResourceItem, auditLog *AuditLog, tmpDir string, verbose bool) (string, bool) {
+	if item.dirPath != "" {
+		listing, err := item.directoryListing()
+		if err != nil {
+			log.Printf("ERROR: Error listing directory for resource %s: %v", item.URI, err)
+			return fmt.Sprintf("Error listing directory: %v", err), false
+		}
+		return listing, true
+	}
+
 	var combinedContent strings.Builder
 
-	// Append static content first
-	if item.Content != "" {
+	// Append static content first, with the current content of its file
+	ok := true
+	if item.contentPath != "" {
+		combinedContent.WriteString(item.staticContent)
+		fileContent, err := os.ReadFile(item.contentPath)
+		if err != nil {
+			log.Printf("ERROR: Error reading file for resource %s: %v", item.URI, err)
+			fmt.Fprintf(&combinedContent, "\nError reading file: %v", err)
+			ok = false
+		}
+		combinedContent.Write(fileContent)
+	} else if item.Content != "" {
 		combinedContent.WriteString(item.Content)
 	}
 
 	// Then, append command output if a command is defined
-	if item.Command != "" {
-		cmdItem := ContextItem{Command: item.Command}
-		output, exitCode, duration, err := executeCommand(cmdItem, nil, tmpDir)
+	if item.Command != "" || len(item.Args) > 0 {
+		output, exitCode, duration, err := executeCommand(ctx, item.commandItem(), item.vars, tmpDir)
+
+		record := commandRecord("resource", exitCode, duration, len(output), err)
+		record.Resource = item.URI
+		record.Parameters = newRedactor(item.secrets).redactValues(auditParameters(item.Variables, item.vars))
+		auditLog.Record(ctx, record)
 
 		if err != nil {
 			log.Printf("ERROR: Error executing command for resource %s (Exit Code: %d): %v", item.URI, exitCode, err)
 			// Append error to content for visibility to the LLM
 			output = fmt.Sprintf("\nError executing command: %v. Output: %s", err, output)
+			ok = false
 		} else {
 			if verbose {
 				log.Printf("Successfully executed command for resource %s, output: %d bytes, %d lines, exit code: %d, duration: %s", item.URI, len(output), countLines(output), exitCode, duration)
@@ -351,50 +689,14 @@ func getResourceContent(item ResourceItem, tmpDir string, verbose bool) (string,
 		combinedContent.WriteString(output)
 	}
 
-	return combinedContent.String(), nil
+	return combinedContent.String(), ok
 }
 
 // registerConfigTools iterates through the configuration and registers
 // declared tools, routing them to sync or async handlers.
-func registerConfigTools(mcpServer *server.MCPServer, cfg *Config, taskStore *TaskStore, tmpDir string, verbose bool) {
+func registerConfigTools(mcpServer *server.MCPServer, cfg *Config, taskStore TaskStore, subscriptions *Subscriptions, auditLog *AuditLog, tmpDir string, verbose bool) {
 	for _, item := range cfg.Specification.Tools {
-		currentItem := item
-		var toolOptions []mcp.ToolOption
-		toolOptions = append(toolOptions, mcp.WithDescription(item.Description))
-
-		for _, paramName := range item.Parameters {
-			toolOptions = append(toolOptions, mcp.WithString(
-				paramName,
-				mcp.Required(),
-				mcp.Description(fmt.Sprintf("Parameter: %s", paramName)),
-			))
-		}
-
-		tool := mcp.NewTool(item.Name, toolOptions...)
-
-		handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			log.Printf("Handling request for tool: %s", currentItem.Name)
-
-			params := make(map[string]interface{})
-			for _, paramName := range currentItem.Parameters {
-				val, err := request.RequireString(paramName)
-				if err != nil {
-					return mcp.NewToolResultError(err.Error()), nil
-				}
-				params[paramName] = val
-			}
-
-			if verbose {
-				log.Printf("Tool parameters: %v", params)
-			}
-
-			if currentItem.Async {
-				return handleAsyncTask(ctx, currentItem, params, taskStore, tmpDir, verbose)
-			}
-			return handleSyncTask(ctx, currentItem, params, tmpDir, verbose)
-		}
-
-		mcpServer.AddTool(tool, handler)
+		mcpServer.AddTools(configTool(item, taskStore, subscriptions, auditLog, tmpDir, verbose))
 
 		logMessage := fmt.Sprintf("Registered tool: %s", item.Name)
 		if item.Async {
@@ -407,19 +709,88 @@ func registerConfigTools(mcpServer *server.MCPServer, cfg *Config, taskStore *Ta
 	}
 }
 
-func handleSyncTask(ctx context.Context, currentItem ContextItem, params map[string]interface{}, tmpDir string, verbose bool) (*mcp.CallToolResult, error) {
-	output, exitCode, duration, err := executeCommand(currentItem, params, tmpDir)
+// configTool builds the MCP tool and handler for a tool from the configuration.
+func configTool(currentItem ContextItem, taskStore TaskStore, subscriptions *Subscriptions, auditLog *AuditLog, tmpDir string, verbose bool) server.ServerTool {
+	var toolOptions []mcp.ToolOption
+	toolOptions = append(toolOptions, mcp.WithDescription(currentItem.Description))
+
+	for i := range currentItem.Parameters {
+		toolOptions = append(toolOptions, currentItem.Parameters[i].toolOption())
+	}
+
+	// Async tools return a task resource, so only synchronous tools have
+	// structured results.
+	if currentItem.StructuredOutput && !currentItem.Async {
+		toolOptions = append(toolOptions, mcp.WithRawOutputSchema(commandResultSchema))
+	}
+
+	tool := mcp.NewTool(currentItem.Name, toolOptions...)
+
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("Handling request for tool: %s (caller: %s)", currentItem.Name, principalName(ctx))
+
+		// Reject invalid arguments before anything is executed.
+		params, err := validateArguments(currentItem.Parameters, request.GetArguments())
+		if err != nil {
+			log.Printf("Rejected request for tool %s: %v", currentItem.Name, err)
+			if paramErr, ok := err.(*ParameterError); ok {
+				return paramErr.toolResult(), nil
+			}
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if verbose {
+			log.Printf("Tool parameters: %s", newRedactor(currentItem.secrets).redact(fmt.Sprint(params)))
+		}
+
+		if currentItem.Async {
+			var progressToken mcp.ProgressToken
+			if request.Params.Meta != nil {
+				progressToken = request.Params.Meta.ProgressToken
+			}
+			return handleAsyncTask(ctx, currentItem, params, taskStore, subscriptions, auditLog, progressToken, tmpDir, verbose)
+		}
+		return handleSyncTask(ctx, currentItem, params, auditLog, tmpDir, verbose)
+	}
+
+	return server.ServerTool{Tool: tool, Handler: handler}
+}
+
+func handleSyncTask(ctx context.Context, currentItem ContextItem, params map[string]interface{}, auditLog *AuditLog, tmpDir string, verbose bool) (*mcp.CallToolResult, error) {
+	var output string
+	var split commandOutput
+	var exitCode int
+	var duration time.Duration
+	var err error
+	if currentItem.StructuredOutput {
+		split, exitCode, duration, err = executeCommandSplit(ctx, currentItem, params, tmpDir)
+		output = split.Stdout + split.Stderr
+	} else {
+		output, exitCode, duration, err = executeCommand(ctx, currentItem, params, tmpDir)
+	}
+
+	record := commandRecord("tool", exitCode, duration, len(output), err)
+	record.Tool = currentItem.Name
+	record.Parameters = newRedactor(currentItem.secrets).redactValues(auditParameters(currentItem.Parameters, params))
+	auditLog.Record(ctx, record)
+
 	if err != nil {
 		log.Printf("ERROR: Error executing command '%s' (Exit Code: %d): %v", currentItem.Name, exitCode, err)
+		if currentItem.StructuredOutput {
+			return structuredToolResult(split, exitCode, duration, err), nil
+		}
 		// Return stderr output to the LLM to help with diagnosing the failure.
 		return mcp.NewToolResultError(fmt.Sprintf("Command failed: %v. Output: %s", err, output)), nil
 	}
 
 	log.Printf("Successfully executed tool '%s', output: %d bytes, %d lines, exit code: %d, duration: %s", currentItem.Name, len(output), countLines(output), exitCode, duration)
+	if currentItem.StructuredOutput {
+		return structuredToolResult(split, exitCode, duration, nil), nil
+	}
 	return mcp.NewToolResultText(output), nil
 }
 
-func handleAsyncTask(ctx context.Context, currentItem ContextItem, params map[string]interface{}, taskStore *TaskStore, tmpDir string, verbose bool) (*mcp.CallToolResult, error) {
+func handleAsyncTask(ctx context.Context, currentItem ContextItem, params map[string]interface{}, taskStore TaskStore, subscriptions *Subscriptions, auditLog *AuditLog, progressToken mcp.ProgressToken, tmpDir string, verbose bool) (*mcp.CallToolResult, error) {
 	srv := server.ServerFromContext(ctx)
 	if srv == nil {
 		log.Println("Error: could not get server from context for async task")
@@ -448,38 +819,20 @@ func handleAsyncTask(ctx context.Context, currentItem ContextItem, params map[st
 	taskURI := fmt.Sprintf("simple-mcp://tasks/%s", jobID)
 
 	task := taskStore.Create(jobID, currentItem.Name)
+	task.Output.limit(currentItem)
 
-	// Create a dynamic resource for this specific task ID. This follows the
-	// standard MCP pattern where a task becomes a subscribable resource.
-	taskResource := mcp.NewResource(
-		taskURI,
-		fmt.Sprintf("Status of async job: %s (Job ID: %s)", currentItem.Name, jobID),
-		mcp.WithMIMEType("text/plain"),
-	)
-	taskResourceHandler := func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		log.Printf("Handling standard MCP resource read for task: %s", jobID)
-		task, ok := taskStore.Get(jobID)
-		if !ok {
-			return []mcp.ResourceContents{
-				mcp.TextResourceContents{
-					URI:      taskURI,
-					MIMEType: "text/plain",
-					Text:     "Status: unknown\nMessage: Task ID not found.",
-				},
-			}, nil
-		}
+	// The job must outlive the request that started it, so it gets its own
+	// context, which can be cancelled through the CancelTask tool. Only the
+	// authenticated caller is carried over.
+	jobCtx, cancelJob := context.WithCancel(withPrincipal(context.Background(), PrincipalFromContext(ctx)))
+	taskStore.SetCancelFunc(jobID, cancelJob)
 
-		contents := []mcp.ResourceContents{
-			mcp.TextResourceContents{
-				URI:      taskURI,
-				MIMEType: "text/plain",
-				Text:     task.FormatStatus(),
-			},
-		}
-		return contents, nil
-	}
+	registerTaskResource(srv, taskStore, jobID, currentItem.Name)
 
-	srv.AddResource(taskResource, taskResourceHandler)
+	auditParams := newRedactor(currentItem.secrets).redactValues(auditParameters(currentItem.Parameters, params))
+	auditLog.Record(ctx, AuditRecord{Kind: "async", Tool: currentItem.Name, TaskID: jobID, Parameters: auditParams, Status: "started"})
+
+	sessionID := sessionIDFromContext(ctx)
 
 	go func() {
 		// Ensure this goroutine does not crash the main server.
@@ -493,10 +846,28 @@ func handleAsyncTask(ctx context.Context, currentItem ContextItem, params map[st
 
 		log.Printf("Starting async job %s: %s", jobID, currentItem.Name)
 		taskStore.SetStatus(jobID, "running", "Job is executing...")
-
-		output, exitCode, duration, err := executeCommand(currentItem, params, tmpDir)
-
-		if err != nil {
+		subscriptions.NotifyUpdated(srv, taskURI)
+
+		// Report output to the client as it arrives.
+		done := make(chan struct{})
+		go watchTaskProgress(srv, subscriptions, sessionID, progressToken, task, taskURI, done)
+		defer subscriptions.NotifyUpdated(srv, taskURI)
+		defer close(done)
+
+		exitCode, duration, err := streamCommand(jobCtx, currentItem, params, tmpDir, task.Output)
+		task.Output.Close()
+		output := task.Output.String()
+
+		record := commandRecord("async", exitCode, duration, len(output), err)
+		record.Tool = currentItem.Name
+		record.TaskID = jobID
+		record.Parameters = auditParams
+		auditLog.Record(jobCtx, record)
+
+		if errors.Is(err, errCommandCancelled) {
+			log.Printf("Async job %s was cancelled after %s, output: %d bytes", jobID, duration, len(output))
+			taskStore.SetStatus(jobID, "cancelled", fmt.Sprintf("Job was cancelled. Partial output: %s", output))
+		} else if err != nil {
 			log.Printf("ERROR: Async job %s finished with status: failed (Exit Code: %d)", jobID, exitCode)
 			errMsg := fmt.Sprintf("%v. Output: %s", err, output)
 			taskStore.SetStatus(jobID, "failed", errMsg)
@@ -515,46 +886,82 @@ func handleAsyncTask(ctx context.Context, currentItem ContextItem, params map[st
 	return mcp.NewToolResultResource(taskURI, initialContents), nil
 }
 
-// registerResources registers the static or dynamic resources defined in the
-// config file. These are separate from the ephemeral task resources.
-func registerResources(mcpServer *server.MCPServer, cfg *Config, tmpDir string, verbose bool) {
-	for _, item := range cfg.Specification.Resources {
-		currentItem := item
+// registerTaskResource creates a dynamic resource for a specific task ID. This
+// follows the standard MCP pattern where a task becomes a subscribable resource.
+func registerTaskResource(srv *server.MCPServer, taskStore TaskStore, jobID string, toolName string) {
+	taskURI := fmt.Sprintf("simple-mcp://tasks/%s", jobID)
+	taskResource := mcp.NewResource(
+		taskURI,
+		fmt.Sprintf("Status of async job: %s (Job ID: %s)", toolName, jobID),
+		mcp.WithMIMEType("text/plain"),
+	)
+	taskResourceHandler := func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		log.Printf("Handling standard MCP resource read for task: %s", jobID)
+		task, ok := taskStore.Get(jobID)
+		if !ok {
+			return []mcp.ResourceContents{
+				mcp.TextResourceContents{
+					URI:      taskURI,
+					MIMEType: "text/plain",
+					Text:     "Status: unknown\nMessage: Task ID not found.",
+				},
+			}, nil
+		}
+
+		contents := []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      taskURI,
+				MIMEType: "text/plain",
+				Text:     task.FormatStatus(),
+			},
+		}
+		return contents, nil
+	}
 
-		resource := mcp.NewResource(
-			currentItem.URI,
-			currentItem.Description,
-			mcp.WithResourceDescription(currentItem.Description),
-			mcp.WithMIMEType("text/plain"),
-		)
+	srv.AddResource(taskResource, taskResourceHandler)
+}
 
-		var handler server.ResourceHandlerFunc
+// registerResources registers the static or dynamic resources defined in the
+// config file. These are separate from the ephemeral task resources. The
+// template for the full output of truncated commands is registered as well.
+func registerResources(mcpServer *server.MCPServer, cfg *Config, auditLog *AuditLog, tmpDir string, verbose bool) {
+	mcpServer.AddResourceTemplates(outputTemplate())
+	for _, item := range cfg.Specification.Resources {
+		if item.URITemplate == "" {
+			mcpServer.AddResources(configResource(item, auditLog, tmpDir, verbose))
+			log.Printf("Registered resource: %s (dynamic: %v)", item.URI, item.Command != "" || len(item.Args) > 0)
+		}
+		if template, ok := configTemplate(item, auditLog, tmpDir, verbose); ok {
+			mcpServer.AddResourceTemplates(template)
+			log.Printf("Registered resource template: %s", template.Template.URITemplate.Raw())
+		}
+	}
+}
 
-		// Combined handler for content, contentFile, and command
-		handler = func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-			if verbose {
-				log.Printf("Handling resource read request for: %s", currentItem.URI)
-			}
+// configResource builds the MCP resource and handler for a resource from the
+// configuration.
+func configResource(currentItem ResourceItem, auditLog *AuditLog, tmpDir string, verbose bool) server.ServerResource {
+	resource := mcp.NewResource(
+		currentItem.URI,
+		currentItem.Description,
+		mcp.WithResourceDescription(currentItem.Description),
+		mcp.WithMIMEType(currentItem.mimeType()),
+	)
 
-			content, err := getResourceContent(currentItem, tmpDir, verbose)
-			if err != nil {
-				// This path should not be reached given the current implementation of getResourceContent,
-				// but is included for robustness.
-				log.Printf("ERROR: Unexpected error getting resource content for %s: %v", currentItem.URI, err)
-				content = fmt.Sprintf("Unexpected error getting resource content for %s: %v", currentItem.URI, err)
-			}
+	// Combined handler for content, contentFile, and command
+	handler := func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		if verbose {
+			log.Printf("Handling resource read request for: %s", currentItem.URI)
+		}
 
-			contents := []mcp.ResourceContents{
-				mcp.TextResourceContents{
-					URI:      currentItem.URI,
-					MIMEType: "text/plain",
-					Text:     content,
-				},
-			}
-			return contents, nil
+		content, err := getResourceContent(ctx, currentItem, auditLog, tmpDir, verbose)
+		if err != nil {
+			// The caller stopped waiting for the content.
+			return nil, err
 		}
-		log.Printf("Registered resource: %s (dynamic: %v)", currentItem.URI, currentItem.Command != "")
 
-		mcpServer.AddResource(resource, handler)
+		return []mcp.ResourceContents{resourceContents(currentItem.URI, currentItem, content)}, nil
 	}
+
+	return server.ServerResource{Resource: resource, Handler: handler}
 }
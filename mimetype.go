@@ -0,0 +1,95 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the MIME types of resources. The type is taken from
+// the mimeType of a resource, or derived from the name and content of its
+// file. Text is returned as is, anything else base64 encoded as a blob.
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultMIMEType is the type of resources without a mimeType or a file.
+const defaultMIMEType = "text/plain"
+
+// checkMIMEType rejects a mimeType that cannot be parsed.
+func checkMIMEType(mimeType string) error {
+	if mimeType == "" {
+		return nil
+	}
+	if _, _, err := mime.ParseMediaType(mimeType); err != nil {
+		return fmt.Errorf("invalid mimeType %q: %w", mimeType, err)
+	}
+	return nil
+}
+
+// mimeType returns the MIME type of the resource advertised to clients,
+// before its content is known.
+func (r ResourceItem) mimeType() string {
+	if r.dirPath != "" {
+		return defaultMIMEType // The listing of the files
+	}
+	if r.MimeType != "" {
+		return r.MimeType
+	}
+	if r.contentPath != "" {
+		if mimeType := mime.TypeByExtension(filepath.Ext(r.contentPath)); mimeType != "" {
+			return mimeType
+		}
+	}
+	return defaultMIMEType
+}
+
+// contentMIMEType returns the MIME type of content read from the resource.
+// The type of a file without a known extension is sniffed from its content.
+func (r ResourceItem) contentMIMEType(content string) string {
+	mimeType := r.mimeType()
+	if r.MimeType == "" && r.contentPath != "" && mimeType == defaultMIMEType {
+		mimeType = http.DetectContentType([]byte(content))
+	}
+	return mimeType
+}
+
+// isTextMIMEType reports whether content of the MIME type is text.
+func isTextMIMEType(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/yaml", "application/x-yaml",
+		"application/javascript", "application/x-sh":
+		return true
+	}
+	return false
+}
+
+// resourceContents returns content of the resource item read at uri, as text
+// or, if it is not valid UTF-8 text, as a base64 encoded blob.
+func resourceContents(uri string, item ResourceItem, content string) mcp.ResourceContents {
+	mimeType := item.contentMIMEType(content)
+	if isTextMIMEType(mimeType) && utf8.ValidString(content) {
+		return mcp.TextResourceContents{URI: uri, MIMEType: mimeType, Text: content}
+	}
+	return mcp.BlobResourceContents{
+		URI:      uri,
+		MIMEType: mimeType,
+		Blob:     base64.StdEncoding.EncodeToString([]byte(content)),
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestResourceContents(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	gzip := "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03"
+	item, docs := testDirectory(t, "")
+	writeTestFile(t, docs, "image.png", png)
+	writeTestFile(t, docs, "messages.1.gz", gzip)
+	writeTestFile(t, docs, "data", "\x00\x01\x02")
+	resources := map[string]ResourceItem{item.URI: item}
+
+	tests := []struct {
+		name     string
+		item     ResourceItem
+		mimeType string // Expected type, empty if it depends on the system
+		blob     string // Expected content of a blob, empty for text
+	}{
+		{"static", ResourceItem{URI: "test://static", Content: "hello"}, "text/plain", ""},
+		{"command with mimeType", ResourceItem{URI: "test://image", Command: `printf '\211PNG'`, MimeType: "image/png"}, "image/png", "\x89PNG"},
+		{"json", ResourceItem{URI: "test://json", Content: `{"a": 1}`, MimeType: "application/json"}, "application/json", ""},
+		{"directory listing", item, "text/plain", ""},
+		{"text file", directoryFileItem(t, resources, "test://docs/b.log"), "", ""},
+		{"image by extension", directoryFileItem(t, resources, "test://docs/image.png"), "image/png", png},
+		{"sniffed gzip", directoryFileItem(t, resources, "test://docs/messages.1.gz"), "", gzip},
+		{"sniffed binary", directoryFileItem(t, resources, "test://docs/data"), "application/octet-stream", "\x00\x01\x02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, _ := getResourceContent(context.Background(), tt.item, nil, "", false)
+			switch c := resourceContents(tt.item.URI, tt.item, content).(type) {
+			case mcp.TextResourceContents:
+				if tt.blob != "" || (tt.mimeType != "" && c.MIMEType != tt.mimeType) {
+					t.Errorf("unexpected text of type %s: %q", c.MIMEType, c.Text)
+				}
+			case mcp.BlobResourceContents:
+				data, err := base64.StdEncoding.DecodeString(c.Blob)
+				if err != nil || string(data) != tt.blob || (tt.mimeType != "" && c.MIMEType != tt.mimeType) {
+					t.Errorf("unexpected blob of type %s: %q, %v", c.MIMEType, data, err)
+				}
+			}
+		})
+	}
+}
+
+// directoryFileItem returns the resource of a file in a directory resource.
+func directoryFileItem(t *testing.T, resources map[string]ResourceItem, uri string) ResourceItem {
+	t.Helper()
+	item, ok := lookupResource(resources, uri)
+	if !ok {
+		t.Fatalf("resource %s not found", uri)
+	}
+	return item
+}
+
+func TestLoadConfig_MimeType(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  resources:
+    - uri: "test://image"
+      command: "true"
+      mimeType: "image/"
+`)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "invalid mimeType") {
+		t.Errorf("expected the invalid mimeType to be rejected, got %v", err)
+	}
+}
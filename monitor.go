@@ -0,0 +1,340 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the monitoring of resources for changes. The command
+// of a resource with intervalSeconds is run again on that schedule while a
+// client is subscribed to the resource, or to a URI matching its uriTemplate,
+// and the files of resources with a
+// contentFile or in a directory are watched with inotify. Subscribers
+// receive notifications/resources/updated when the content changed, and the
+// subscribers of a directory when files were added or removed.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ResourceMonitor notifies subscribers of resources about changes of their
+// content.
+type ResourceMonitor struct {
+	server        *server.MCPServer
+	subscriptions *Subscriptions
+	auditLog      *AuditLog
+	tmpDir        string
+	verbose       bool
+
+	mu  sync.Mutex
+	run *monitorRun // Monitoring of the current resources
+}
+
+// monitorRun monitors one set of resources until it is stopped, e.g. when
+// the configuration is reloaded.
+type monitorRun struct {
+	stop chan struct{}
+
+	mu   sync.Mutex
+	last map[string][sha256.Size]byte // URI or path -> hash of the content last seen
+}
+
+// NewResourceMonitor creates a monitor for the resources of mcpServer. It
+// does nothing until Update is called.
+func NewResourceMonitor(mcpServer *server.MCPServer, subscriptions *Subscriptions, auditLog *AuditLog, tmpDir string, verbose bool) *ResourceMonitor {
+	return &ResourceMonitor{
+		server:        mcpServer,
+		subscriptions: subscriptions,
+		auditLog:      auditLog,
+		tmpDir:        tmpDir,
+		verbose:       verbose,
+	}
+}
+
+// Update replaces the monitored resources.
+func (m *ResourceMonitor) Update(resources map[string]ResourceItem) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.run != nil {
+		close(m.run.stop)
+	}
+	run := &monitorRun{stop: make(chan struct{}), last: make(map[string][sha256.Size]byte)}
+	m.run = run
+
+	files := make(map[string][]ResourceItem) // Path -> resources containing it
+	var dirs []ResourceItem
+	for _, item := range resources {
+		if item.dirPath != "" {
+			dirs = append(dirs, item)
+		}
+		if item.contentPath != "" {
+			if files[item.contentPath] == nil {
+				if data, err := os.ReadFile(item.contentPath); err == nil {
+					run.changed(item.contentPath, string(data))
+				}
+			}
+			files[item.contentPath] = append(files[item.contentPath], item)
+		}
+		if item.IntervalSeconds > 0 && (item.Command != "" || len(item.Args) > 0) {
+			go m.poll(run, item, resources)
+		}
+	}
+	if len(files) > 0 || len(dirs) > 0 {
+		if err := m.watchFiles(run, files, dirs); err != nil {
+			log.Printf("WARNING: Could not watch the files of resources for changes: %v", err)
+		}
+	}
+}
+
+// changed records content as the current content of a resource URI or a file
+// and reports whether it differs from the content seen before. The first
+// content seen is not a change.
+func (run *monitorRun) changed(key, content string) bool {
+	sum := sha256.Sum256([]byte(content))
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	last, ok := run.last[key]
+	run.last[key] = sum
+	return ok && last != sum
+}
+
+// seen reports whether content of uri was seen before.
+func (run *monitorRun) seen(uri string) bool {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	_, ok := run.last[uri]
+	return ok
+}
+
+// poll runs the command of item every intervalSeconds while a client is
+// subscribed to it. A resource with a uriTemplate is run for every subscribed
+// URI it serves, as looked up in resources.
+func (m *ResourceMonitor) poll(run *monitorRun, item ResourceItem, resources map[string]ResourceItem) {
+	ticker := time.NewTicker(time.Duration(item.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-run.stop:
+			return
+		case <-ticker.C:
+		}
+		if item.URITemplate == "" {
+			if len(m.subscriptions.Subscribers(item.URI)) > 0 {
+				m.refresh(run, item)
+			}
+			continue
+		}
+		for _, uri := range m.subscriptions.URIs() {
+			if expanded, ok := lookupResource(resources, uri); ok && expanded.id() == item.id() {
+				m.refresh(run, expanded)
+			}
+		}
+	}
+}
+
+// refresh runs the command of item and notifies the subscribers if the
+// output changed. The output also refreshes the cache, so the client reads
+// the content it was notified about.
+func (m *ResourceMonitor) refresh(run *monitorRun, item ResourceItem) {
+	// The content the subscriber read last is the one to compare with.
+	if content, ok := resourceCache.Peek(item); ok && !run.seen(item.URI) {
+		run.changed(item.URI, content)
+	}
+	content, ok := evaluateResource(context.Background(), item, m.auditLog, m.tmpDir, m.verbose)
+	if !ok {
+		return
+	}
+	resourceCache.Put(item, content)
+	if run.changed(item.URI, content) {
+		m.notify(item.URI)
+	}
+}
+
+// watchFiles watches the files of resources and the directory resources for
+// changes until run is stopped. The directories are watched rather than the
+// files, so that files replaced by renaming another file over them are seen
+// as well.
+func (m *ResourceMonitor) watchFiles(run *monitorRun, files map[string][]ResourceItem, dirs []ResourceItem) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]bool)
+	for path := range files {
+		dir := filepath.Dir(path)
+		if watched[dir] {
+			continue
+		}
+		watched[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+	for _, item := range dirs {
+		if err := watcher.Add(item.dirPath); err != nil {
+			watcher.Close()
+			return err
+		}
+		watchSubdirectories(watcher, item, item.dirPath)
+	}
+
+	go func() {
+		defer watcher.Close()
+		// Files are often written in several steps, so they are read once
+		// the changes settled.
+		timers := make(map[string]*time.Timer)
+		ops := make(map[string]fsnotify.Op) // Path -> changes since its timer started
+		settled := make(chan string)
+		for {
+			select {
+			case <-run.stop:
+				for _, timer := range timers {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				path := event.Name
+				_, relevant := files[path]
+				for _, item := range dirs {
+					if _, ok := directoryRelPath(item, path); ok {
+						relevant = true
+						if event.Has(fsnotify.Create) {
+							watchSubdirectories(watcher, item, path)
+						}
+					}
+				}
+				if !relevant {
+					continue
+				}
+				if timer := timers[path]; timer != nil {
+					timer.Stop()
+				}
+				ops[path] |= event.Op
+				timers[path] = time.AfterFunc(reloadDelay, func() {
+					select {
+					case settled <- path:
+					case <-run.stop:
+					}
+				})
+			case path := <-settled:
+				op := ops[path]
+				if op == 0 {
+					continue // Already handled with a later change
+				}
+				delete(ops, path)
+				delete(timers, path)
+				if items, ok := files[path]; ok {
+					m.fileChanged(run, path, items)
+				}
+				for _, item := range dirs {
+					if rel, ok := directoryRelPath(item, path); ok {
+						m.directoryChanged(item, rel, op)
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("ERROR: Watching the files of resources failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// fileChanged notifies the subscribers of the resources containing path if
+// its content changed.
+func (m *ResourceMonitor) fileChanged(run *monitorRun, path string, items []ResourceItem) {
+	select {
+	case <-run.stop:
+		return
+	default:
+	}
+	// A removed file is a change as well, the resource reports the error.
+	data, _ := os.ReadFile(path)
+	if !run.changed(path, string(data)) {
+		return
+	}
+	for _, item := range items {
+		resourceCache.Invalidate(item.URI)
+		m.notify(item.URI)
+	}
+}
+
+// directoryRelPath returns the slash-separated path of path relative to the
+// directory of a directory resource, if it is in that directory.
+func directoryRelPath(item ResourceItem, path string) (string, bool) {
+	rel, err := filepath.Rel(item.dirPath, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// watchSubdirectories adds the subdirectories of dir, which is in the
+// directory of a directory resource or that directory itself, to watcher,
+// down to maxDepth.
+func watchSubdirectories(watcher *fsnotify.Watcher, item ResourceItem, dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == item.dirPath {
+			return nil
+		}
+		rel, _ := directoryRelPath(item, path)
+		if item.MaxDepth > 0 && strings.Count(rel, "/")+1 >= item.MaxDepth {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			log.Printf("WARNING: Could not watch %s for changes of resource %s: %v", path, item.URI, err)
+		}
+		return nil
+	})
+}
+
+// directoryChanged notifies the subscribers of a file in a directory
+// resource, and of the directory when files were added or removed. The
+// contents of the files are not compared, as that would mean reading the
+// whole directory.
+func (m *ResourceMonitor) directoryChanged(item ResourceItem, rel string, op fsnotify.Op) {
+	if !item.matchesFilter(rel) {
+		return
+	}
+	if info, err := os.Stat(filepath.Join(item.dirPath, filepath.FromSlash(rel))); err == nil && info.IsDir() {
+		return
+	}
+	uri := directoryResourceURI(item.URI, rel)
+	resourceCache.Invalidate(uri)
+	m.notify(uri)
+	if op.Has(fsnotify.Create) || op.Has(fsnotify.Remove) || op.Has(fsnotify.Rename) {
+		m.notify(item.URI)
+	}
+}
+
+// notify sends notifications/resources/updated to the subscribers of uri.
+func (m *ResourceMonitor) notify(uri string) {
+	if m.verbose {
+		log.Printf("Resource %s changed, notifying %d subscribers", uri, len(m.subscriptions.Subscribers(uri)))
+	}
+	m.subscriptions.NotifyUpdated(m.server, uri)
+}
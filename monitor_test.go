@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// testSession is a client session collecting its notifications.
+type testSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) Initialize()                                         {}
+func (s *testSession) Initialized() bool                                   { return true }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s *testSession) SessionID() string                                   { return s.id }
+
+// subscribedSession registers a session subscribed to uri.
+func subscribedSession(t *testing.T, mcpServer *server.MCPServer, subscriptions *Subscriptions, uri string) *testSession {
+	t.Helper()
+	session := &testSession{id: "session-1", notifications: make(chan mcp.JSONRPCNotification, 10)}
+	if err := mcpServer.RegisterSession(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+	subscriptions.Subscribe(session.id, uri)
+	return session
+}
+
+// expectUpdated waits for notifications/resources/updated for uri.
+func expectUpdated(t *testing.T, session *testSession, uri string, timeout time.Duration) {
+	t.Helper()
+	select {
+	case notification := <-session.notifications:
+		if notification.Method != "notifications/resources/updated" || notification.Params.AdditionalFields["uri"] != uri {
+			t.Errorf("unexpected notification %+v", notification)
+		}
+	case <-time.After(timeout):
+		t.Errorf("expected a notification for %s", uri)
+	}
+}
+
+func TestResourceMonitor_Command(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "state")
+	writeTestFile(t, dir, "state", "old")
+
+	mcpServer := server.NewMCPServer("test", "v1", server.WithResourceCapabilities(true, true))
+	subscriptions := NewSubscriptions()
+	session := subscribedSession(t, mcpServer, subscriptions, "test://state")
+	item := ResourceItem{URI: "test://state", Command: "cat " + file, IntervalSeconds: 1}
+
+	monitor := NewResourceMonitor(mcpServer, subscriptions, nil, "", false)
+	monitor.Update(map[string]ResourceItem{item.URI: item})
+	defer monitor.Update(nil)
+
+	// The first evaluation is the baseline.
+	time.Sleep(1500 * time.Millisecond)
+	select {
+	case notification := <-session.notifications:
+		t.Fatalf("unexpected notification before a change: %+v", notification)
+	default:
+	}
+
+	writeTestFile(t, dir, "state", "new")
+	expectUpdated(t, session, item.URI, 2*time.Second)
+	if content, _ := getResourceContent(context.Background(), item, nil, "", false); content != "new" {
+		t.Errorf("expected the cache to hold the new content, got %q", content)
+	}
+}
+
+func TestResourceMonitor_Template(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "state", "old")
+	path := writeTestFile(t, dir, "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  resources:
+    - uriTemplate: "test://files/{name}"
+      args: ["cat", "`+dir+`/{{.name}}"]
+      intervalSeconds: 1
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	item := cfg.Specification.Resources[0]
+
+	// The URIs matching the template are polled once they are subscribed.
+	mcpServer := server.NewMCPServer("test", "v1", server.WithResourceCapabilities(true, true))
+	subscriptions := NewSubscriptions()
+	session := subscribedSession(t, mcpServer, subscriptions, "test://files/state")
+	monitor := NewResourceMonitor(mcpServer, subscriptions, nil, "", false)
+	monitor.Update(map[string]ResourceItem{item.id(): item})
+	defer monitor.Update(nil)
+
+	time.Sleep(1500 * time.Millisecond)
+	writeTestFile(t, dir, "state", "new")
+	expectUpdated(t, session, "test://files/state", 2*time.Second)
+}
+
+func TestResourceMonitor_File(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "motd", "hello")
+	path := writeTestFile(t, dir, "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  resources:
+    - uri: "test://motd"
+      content: "Message: "
+      contentFile: motd
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	item := cfg.Specification.Resources[0]
+
+	mcpServer := server.NewMCPServer("test", "v1", server.WithResourceCapabilities(true, true))
+	subscriptions := NewSubscriptions()
+	session := subscribedSession(t, mcpServer, subscriptions, item.URI)
+	monitor := NewResourceMonitor(mcpServer, subscriptions, nil, "", false)
+	monitor.Update(map[string]ResourceItem{item.URI: item})
+	defer monitor.Update(nil)
+
+	// Replace the file like an editor does.
+	writeTestFile(t, dir, "motd.tmp", "goodbye")
+	if err := os.Rename(filepath.Join(dir, "motd.tmp"), filepath.Join(dir, "motd")); err != nil {
+		t.Fatal(err)
+	}
+	expectUpdated(t, session, item.URI, 2*time.Second)
+	if content, _ := getResourceContent(context.Background(), item, nil, "", false); content != "Message: goodbye" {
+		t.Errorf("expected the new content of the file, got %q", content)
+	}
+}
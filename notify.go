@@ -0,0 +1,382 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides resource subscriptions and change notifications.
+// mcp-go advertises the resource subscribe capability but does not implement
+// the resources/subscribe and resources/unsubscribe requests, so they are
+// intercepted in front of the transport and tracked here.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// progressInterval is how often the output of running async tasks is checked
+// for changes that should be reported to clients.
+const progressInterval = 2 * time.Second
+
+// maxRequestBytes limits the size of a request posted to the HTTP endpoints.
+// Requests carry tool parameters, e.g. the content of a file written to the
+// scratch space, so the limit is generous.
+const maxRequestBytes = 16 << 20
+
+// Subscriptions is a thread-safe registry of the resource URIs each client
+// session has subscribed to.
+type Subscriptions struct {
+	mu     sync.RWMutex
+	subs   map[string]map[string]bool // URI -> set of session IDs
+	policy func() *Policy             // Current access policy, if any
+}
+
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{
+		subs: make(map[string]map[string]bool),
+	}
+}
+
+// SetPolicy makes subscriptions to resources require read access under the
+// policy returned by policy.
+func (s *Subscriptions) SetPolicy(policy func() *Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// canSubscribe reports whether the caller may subscribe to the resource at
+// uri, which requires being allowed to read it.
+func (s *Subscriptions) canSubscribe(ctx context.Context, uri string) bool {
+	s.mu.RLock()
+	policy := s.policy
+	s.mu.RUnlock()
+	return policy == nil || policy().CanReadResource(ctx, uri)
+}
+
+// Subscribe records that a session wants to be notified about changes of uri.
+func (s *Subscriptions) Subscribe(sessionID, uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subs[uri] == nil {
+		s.subs[uri] = make(map[string]bool)
+	}
+	s.subs[uri][sessionID] = true
+}
+
+// Unsubscribe removes a single subscription.
+func (s *Subscriptions) Unsubscribe(sessionID, uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subs[uri], sessionID)
+	if len(s.subs[uri]) == 0 {
+		delete(s.subs, uri)
+	}
+}
+
+// RemoveSession drops all subscriptions of a session that has gone away.
+func (s *Subscriptions) RemoveSession(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for uri, sessions := range s.subs {
+		delete(sessions, sessionID)
+		if len(sessions) == 0 {
+			delete(s.subs, uri)
+		}
+	}
+}
+
+// Subscribers returns the IDs of the sessions subscribed to uri.
+func (s *Subscriptions) Subscribers(uri string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []string
+	for sessionID := range s.subs[uri] {
+		sessions = append(sessions, sessionID)
+	}
+	return sessions
+}
+
+// URIs returns the URIs with at least one subscriber.
+func (s *Subscriptions) URIs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	uris := make([]string, 0, len(s.subs))
+	for uri := range s.subs {
+		uris = append(uris, uri)
+	}
+	return uris
+}
+
+// NotifyUpdated sends notifications/resources/updated for uri to every
+// subscribed session. Delivery is best effort; sessions without an open
+// notification stream simply miss the update.
+func (s *Subscriptions) NotifyUpdated(mcpServer *server.MCPServer, uri string) {
+	for _, sessionID := range s.Subscribers(uri) {
+		err := mcpServer.SendNotificationToSpecificClient(sessionID, "notifications/resources/updated", map[string]any{
+			"uri": uri,
+		})
+		if err == server.ErrSessionNotFound {
+			s.RemoveSession(sessionID)
+		}
+	}
+}
+
+// subscriptionRequest is the part of a JSON-RPC message needed to handle
+// subscription requests.
+type subscriptionRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params struct {
+		URI string `json:"uri"`
+	} `json:"params"`
+}
+
+// handleRequest records the subscription change if message is a
+// resources/subscribe or resources/unsubscribe request of the session, and
+// returns the ID of the request. It reports false for any other message. A
+// subscription the caller is not allowed to make is not recorded and
+// returned as an error.
+func (s *Subscriptions) handleRequest(ctx context.Context, sessionID string, message []byte) (json.RawMessage, bool, error) {
+	var request subscriptionRequest
+	if json.Unmarshal(message, &request) != nil || request.ID == nil {
+		return nil, false, nil
+	}
+
+	switch request.Method {
+	case "resources/subscribe":
+		if !s.canSubscribe(ctx, request.Params.URI) {
+			log.Printf("Denied subscription to resource %s to %s", request.Params.URI, principalName(ctx))
+			return request.ID, true, fmt.Errorf("access denied to resource %s", request.Params.URI)
+		}
+		s.Subscribe(sessionID, request.Params.URI)
+		log.Printf("Session %s subscribed to %s", sessionID, request.Params.URI)
+	case "resources/unsubscribe":
+		s.Unsubscribe(sessionID, request.Params.URI)
+		log.Printf("Session %s unsubscribed from %s", sessionID, request.Params.URI)
+	default:
+		return nil, false, nil
+	}
+	return request.ID, true, nil
+}
+
+// RequestHook rejects resources/subscribe requests the caller is not allowed
+// to make. rewriteRequest leaves them in place for mcp-go, which answers them
+// with the returned error. It is registered with
+// server.Hooks.AddOnRequestInitialization.
+func (s *Subscriptions) RequestHook(ctx context.Context, id any, message any) error {
+	raw, ok := message.(json.RawMessage)
+	if !ok {
+		return nil
+	}
+	var request subscriptionRequest
+	if json.Unmarshal(raw, &request) != nil || request.Method != "resources/subscribe" {
+		return nil
+	}
+	if !s.canSubscribe(ctx, request.Params.URI) {
+		return fmt.Errorf("access denied to resource %s", request.Params.URI)
+	}
+	return nil
+}
+
+// rewriteRequest handles a subscription request of the session like
+// handleRequest and replaces it by a ping with the same ID, whose empty
+// result is the answer to the request. This is for transports where the
+// response cannot be written here, but is sent by mcp-go. A denied
+// subscription and other messages are returned unchanged, so that mcp-go
+// rejects the former through RequestHook.
+func (s *Subscriptions) rewriteRequest(ctx context.Context, sessionID string, message []byte) []byte {
+	id, ok, err := s.handleRequest(ctx, sessionID, message)
+	if !ok || err != nil {
+		return message
+	}
+	ping, _ := json.Marshal(map[string]any{
+		"jsonrpc": mcp.JSONRPC_VERSION,
+		"id":      id,
+		"method":  mcp.MethodPing,
+	})
+	return ping
+}
+
+// readRequestBody reads the body of a request posted to an HTTP endpoint,
+// up to maxRequestBytes. On error the response has been written.
+func readRequestBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+// Middleware answers resources/subscribe and resources/unsubscribe requests
+// to the Streamable HTTP handler and passes everything else on to next.
+func (s *Subscriptions) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, ok := readRequestBody(w, r)
+		if !ok {
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sessionID := r.Header.Get(server.HeaderKeySessionID)
+		if sessionID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id, ok, err := s.handleRequest(r.Context(), sessionID, body)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		response := map[string]any{
+			"jsonrpc": mcp.JSONRPC_VERSION,
+			"id":      id,
+			"result":  map[string]any{},
+		}
+		if err != nil {
+			delete(response, "result")
+			response["error"] = map[string]any{"code": mcp.INVALID_REQUEST, "message": err.Error()}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// SSEMiddleware handles resources/subscribe and resources/unsubscribe
+// requests posted to the SSE message endpoint. The SSE handler sends the
+// responses over the event stream of the session.
+func (s *Subscriptions) SSEMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, ok := readRequestBody(w, r)
+		if !ok {
+			return
+		}
+		if sessionID := r.URL.Query().Get("sessionId"); sessionID != "" {
+			body = s.rewriteRequest(r.Context(), sessionID, body)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// stdioSessionID is the ID of the single session of the stdio transport.
+const stdioSessionID = "stdio"
+
+// StdioReader handles resources/subscribe and resources/unsubscribe requests
+// read from in by the stdio transport, which receives one message per line.
+func (s *Subscriptions) StdioReader(in io.Reader) io.Reader {
+	return &subscriptionReader{subscriptions: s, in: bufio.NewReader(in)}
+}
+
+type subscriptionReader struct {
+	subscriptions *Subscriptions
+	in            *bufio.Reader
+	pending       []byte // Rest of the current line
+}
+
+func (r *subscriptionReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		line, err := r.in.ReadBytes('\n')
+		if len(line) == 0 {
+			return 0, err
+		}
+		if message, ok := bytes.CutSuffix(line, []byte("\n")); ok {
+			// The stdio transport has no authenticated principal.
+			line = append(r.subscriptions.rewriteRequest(context.Background(), stdioSessionID, message), '\n')
+		}
+		r.pending = line
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// watchTaskProgress reports new output of a running async task until done is
+// closed. The client that started the task receives notifications/progress
+// if it supplied a progress token, and subscribers of the task resource
+// receive notifications/resources/updated.
+func watchTaskProgress(mcpServer *server.MCPServer, subscriptions *Subscriptions, sessionID string, progressToken mcp.ProgressToken, task *AsyncTask, taskURI string, done <-chan struct{}) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	lastLen := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		currentLen := task.Output.Len()
+		if currentLen == lastLen {
+			continue
+		}
+		lastLen = currentLen
+
+		if progressToken != nil && sessionID != "" {
+			mcpServer.SendNotificationToSpecificClient(sessionID, "notifications/progress", map[string]any{
+				"progressToken": progressToken,
+				"progress":      currentLen,
+				"message":       lastLine(task.Output.Tail(1)),
+			})
+		}
+		subscriptions.NotifyUpdated(mcpServer, taskURI)
+	}
+}
+
+// lastLine returns the last line of s, shortened for use in a progress message.
+func lastLine(s string) string {
+	const maxLen = 200
+	if idx := strings.LastIndexByte(s, '\n'); idx >= 0 {
+		s = s[idx+1:]
+	}
+	if len(s) > maxLen {
+		s = s[:maxLen] + "..."
+	}
+	return s
+}
+
+// sessionIDFromContext returns the ID of the client session handling the
+// current request, or an empty string if there is none.
+func sessionIDFromContext(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
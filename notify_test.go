@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestSubscriptions(t *testing.T) {
+	subs := NewSubscriptions()
+	subs.Subscribe("s1", "simple-mcp://a")
+	subs.Subscribe("s2", "simple-mcp://a")
+	subs.Subscribe("s1", "simple-mcp://b")
+
+	got := subs.Subscribers("simple-mcp://a")
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "s1" || got[1] != "s2" {
+		t.Errorf("unexpected subscribers: %v", got)
+	}
+
+	subs.Unsubscribe("s2", "simple-mcp://a")
+	if got := subs.Subscribers("simple-mcp://a"); len(got) != 1 {
+		t.Errorf("expected 1 subscriber after unsubscribe, got %v", got)
+	}
+
+	subs.RemoveSession("s1")
+	if got := subs.Subscribers("simple-mcp://a"); len(got) != 0 {
+		t.Errorf("expected no subscribers after session removal, got %v", got)
+	}
+	if got := subs.Subscribers("simple-mcp://b"); len(got) != 0 {
+		t.Errorf("expected no subscribers after session removal, got %v", got)
+	}
+}
+
+func TestSubscriptions_Middleware(t *testing.T) {
+	subs := NewSubscriptions()
+	passedThrough := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passedThrough = true
+	})
+	handler := subs.Middleware(next)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(server.HeaderKeySessionID, "session-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"jsonrpc":"2.0","id":7,"method":"resources/subscribe","params":{"uri":"simple-mcp://tasks/x"}}`)
+	if passedThrough {
+		t.Error("subscribe request should not be passed to the MCP handler")
+	}
+	if !strings.Contains(rec.Body.String(), `"id":7`) || !strings.Contains(rec.Body.String(), `"result":{}`) {
+		t.Errorf("unexpected response: %s", rec.Body.String())
+	}
+	if got := subs.Subscribers("simple-mcp://tasks/x"); len(got) != 1 || got[0] != "session-1" {
+		t.Errorf("expected session-1 to be subscribed, got %v", got)
+	}
+
+	post(`{"jsonrpc":"2.0","id":8,"method":"resources/unsubscribe","params":{"uri":"simple-mcp://tasks/x"}}`)
+	if got := subs.Subscribers("simple-mcp://tasks/x"); len(got) != 0 {
+		t.Errorf("expected no subscribers, got %v", got)
+	}
+
+	post(`{"jsonrpc":"2.0","id":9,"method":"tools/list"}`)
+	if !passedThrough {
+		t.Error("other requests should be passed to the MCP handler")
+	}
+}
+
+func TestSubscriptions_Stdio(t *testing.T) {
+	subs := NewSubscriptions()
+	mcpServer := server.NewMCPServer("test", "v1", server.WithResourceCapabilities(true, false))
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.NewStdioServer(mcpServer).Listen(ctx, subs.StdioReader(stdinReader), stdoutWriter)
+	stdout := bufio.NewScanner(stdoutReader)
+	request := func(message string) string {
+		t.Helper()
+		if _, err := io.WriteString(stdinWriter, message+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		if !stdout.Scan() {
+			t.Fatalf("no response to %s", message)
+		}
+		return stdout.Text()
+	}
+
+	request(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	if response := request(`{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"simple-mcp://a"}}`); response != `{"jsonrpc":"2.0","id":2,"result":{}}` {
+		t.Errorf("unexpected response: %s", response)
+	}
+	if got := subs.Subscribers("simple-mcp://a"); len(got) != 1 || got[0] != stdioSessionID {
+		t.Fatalf("expected the stdio session to be subscribed, got %v", got)
+	}
+	subs.NotifyUpdated(mcpServer, "simple-mcp://a")
+	if !stdout.Scan() || !strings.Contains(stdout.Text(), `"method":"notifications/resources/updated"`) {
+		t.Errorf("expected the notification, got %s", stdout.Text())
+	}
+	if response := request(`{"jsonrpc":"2.0","id":3,"method":"resources/unsubscribe","params":{"uri":"simple-mcp://a"}}`); response != `{"jsonrpc":"2.0","id":3,"result":{}}` {
+		t.Errorf("unexpected response: %s", response)
+	}
+	if got := subs.Subscribers("simple-mcp://a"); len(got) != 0 {
+		t.Errorf("expected no subscribers, got %v", got)
+	}
+}
+
+func TestSubscriptions_SSEMiddleware(t *testing.T) {
+	subs := NewSubscriptions()
+	var forwarded string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		forwarded = string(body)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/message?sessionId=session-1", strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"resources/subscribe","params":{"uri":"simple-mcp://a"}}`))
+	subs.SSEMiddleware(next).ServeHTTP(httptest.NewRecorder(), req)
+	// The SSE handler answers the ping on the event stream of the session.
+	if forwarded != `{"id":7,"jsonrpc":"2.0","method":"ping"}` {
+		t.Errorf("expected a ping to be forwarded, got %s", forwarded)
+	}
+	if got := subs.Subscribers("simple-mcp://a"); len(got) != 1 || got[0] != "session-1" {
+		t.Errorf("expected session-1 to be subscribed, got %v", got)
+	}
+}
+
+func TestSubscriptions_Policy(t *testing.T) {
+	policy := NewPolicy(&Config{Specification: Spec{
+		Roles:     []RoleDefinition{{Name: "admin", Members: []string{"alice"}}},
+		Resources: []ResourceItem{{URI: "test://secret", Roles: []string{"admin"}}},
+	}}, NewTaskStore(10))
+	subs := NewSubscriptions()
+	subs.SetPolicy(func() *Policy { return policy })
+	handler := subs.Middleware(http.NotFoundHandler())
+	subscribe := func(name string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"resources/subscribe","params":{"uri":"test://secret"}}`))
+		req.Header.Set(server.HeaderKeySessionID, name)
+		req = req.WithContext(withPrincipal(req.Context(), &Principal{Name: name}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	// Callers not allowed to read a resource cannot subscribe to it.
+	if response := subscribe("bob"); !strings.Contains(response, `"id":7`) || !strings.Contains(response, "access denied to resource test://secret") {
+		t.Errorf("expected an error, got %s", response)
+	}
+	if response := subscribe("alice"); !strings.Contains(response, `"result":{}`) {
+		t.Errorf("expected the subscription to succeed, got %s", response)
+	}
+	if got := subs.Subscribers("test://secret"); len(got) != 1 || got[0] != "alice" {
+		t.Errorf("expected only alice to be subscribed, got %v", got)
+	}
+
+	// Transports answered by mcp-go leave the request to RequestHook.
+	hooks := &server.Hooks{}
+	hooks.AddOnRequestInitialization(subs.RequestHook)
+	mcpServer := server.NewMCPServer("test", "v1", server.WithResourceCapabilities(true, false), server.WithHooks(hooks))
+	message := subs.rewriteRequest(context.Background(), stdioSessionID, []byte(`{"jsonrpc":"2.0","id":8,"method":"resources/subscribe","params":{"uri":"test://secret"}}`))
+	response, _ := json.Marshal(mcpServer.HandleMessage(context.Background(), message))
+	if !strings.Contains(string(response), `"id":8`) || !strings.Contains(string(response), "access denied to resource test://secret") {
+		t.Errorf("expected an error, got %s", response)
+	}
+	if got := subs.Subscribers("test://secret"); len(got) != 1 {
+		t.Errorf("expected the stdio session not to be subscribed, got %v", got)
+	}
+}
+
+func TestSubscriptions_RequestTooLarge(t *testing.T) {
+	subs := NewSubscriptions()
+	for name, handler := range map[string]http.Handler{
+		"http": subs.Middleware(http.NotFoundHandler()),
+		"sse":  subs.SSEMiddleware(http.NotFoundHandler()),
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/mcp?sessionId=session-1", strings.NewReader(strings.Repeat(" ", maxRequestBytes+1)))
+		req.Header.Set(server.HeaderKeySessionID, "session-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected status 413, got %d", name, rec.Code)
+		}
+	}
+}
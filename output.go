@@ -0,0 +1,256 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the bounded collection of command output. A command
+// printing more than maxOutputBytes would flood the context of the LLM and
+// the memory of the server, so only the beginning and the end of its output
+// are kept, and the full output can be written to a file instead.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// outputURIPrefix is the prefix of the resources with the full output of
+// truncated commands.
+const outputURIPrefix = "simple-mcp://output/"
+
+// spillRetention is how long the full output of a command is kept after the
+// command finished.
+const spillRetention = time.Hour
+
+// spillOwner is the tool or resource whose command produced a spilled
+// output. Only callers allowed to use the tool or read the resource may read
+// the output.
+type spillOwner struct {
+	tool     string
+	resource string
+}
+
+// spillStore keeps the files with the full output of truncated commands. They
+// are written to a private directory of the server, outside of the scratch
+// space, and read through resources, so that access to them is checked.
+type spillStore struct {
+	mu     sync.Mutex
+	dir    string                // Created with the first file
+	owners map[string]spillOwner // File name -> owner
+}
+
+// spilledOutputs holds the full output of all truncated commands.
+var spilledOutputs = &spillStore{owners: make(map[string]spillOwner)}
+
+// create creates a file for the full output of a command of owner.
+func (s *spillStore) create(owner spillOwner) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "simple-mcp-output-")
+		if err != nil {
+			return nil, err
+		}
+		s.dir = dir
+	}
+	f, err := os.CreateTemp(s.dir, "output-*.txt")
+	if err != nil {
+		return nil, err
+	}
+	s.owners[filepath.Base(f.Name())] = owner
+	return f, nil
+}
+
+// remove deletes the named file, if it still exists.
+func (s *spillStore) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.owners[name]; !ok {
+		return
+	}
+	delete(s.owners, name)
+	os.Remove(filepath.Join(s.dir, name))
+}
+
+// owner returns the owner of the named file.
+func (s *spillStore) owner(name string) (spillOwner, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	owner, ok := s.owners[name]
+	return owner, ok
+}
+
+// read returns the content of the named file.
+func (s *spillStore) read(name string) (string, error) {
+	s.mu.Lock()
+	_, ok := s.owners[name]
+	dir := s.dir
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("output %s not found", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// outputBuffer collects the output of a command. With a limit, it keeps the
+// first and the last half of the limit and counts the bytes in between.
+type outputBuffer struct {
+	max     int         // Limit of the kept output, 0 for no limit
+	spillTo *spillOwner // Owner of the full output, if it is kept
+
+	head  []byte
+	tail  []byte // Holds up to twice the tail size to avoid copying on every write
+	total int64
+	spill *os.File
+}
+
+// newOutputBuffer returns a buffer keeping at most max bytes. If spillTo is
+// set, the full output is written to a file of spilledOutputs once it exceeds
+// max.
+func newOutputBuffer(max int, spillTo *spillOwner) *outputBuffer {
+	return &outputBuffer{max: max, spillTo: spillTo}
+}
+
+func (b *outputBuffer) headSize() int { return b.max / 2 }
+func (b *outputBuffer) tailSize() int { return b.max - b.max/2 }
+
+// Write implements io.Writer. It never fails, as the output of a command
+// must be consumed even if it cannot be kept.
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += int64(n)
+	if b.spill != nil {
+		if _, err := b.spill.Write(p); err != nil {
+			b.closeSpill()
+		}
+	}
+	if b.max <= 0 {
+		b.head = append(b.head, p...)
+		return n, nil
+	}
+
+	if free := b.headSize() - len(b.head); free > 0 {
+		take := min(free, len(p))
+		b.head = append(b.head, p[:take]...)
+		p = p[take:]
+	}
+	b.tail = append(b.tail, p...)
+
+	// Nothing has been dropped yet, so the full output is still there.
+	if len(b.tail) > b.tailSize() && b.spill == nil && b.spillTo != nil {
+		b.startSpill()
+	}
+	if len(b.tail) > 2*b.tailSize() {
+		b.tail = append([]byte(nil), b.tail[len(b.tail)-b.tailSize():]...)
+	}
+	return n, nil
+}
+
+// startSpill creates the file for the full output and writes the output so
+// far to it.
+func (b *outputBuffer) startSpill() {
+	f, err := spilledOutputs.create(*b.spillTo)
+	if err != nil {
+		b.spillTo = nil
+		return
+	}
+	b.spill = f
+	if _, err := f.Write(b.head); err != nil {
+		b.closeSpill()
+		return
+	}
+	if _, err := f.Write(b.tail); err != nil {
+		b.closeSpill()
+	}
+}
+
+// closeSpill gives up on a spill file that could not be written.
+func (b *outputBuffer) closeSpill() {
+	b.spill.Close()
+	spilledOutputs.remove(filepath.Base(b.spill.Name()))
+	b.spill = nil
+	b.spillTo = nil
+}
+
+// Len returns the number of bytes written.
+func (b *outputBuffer) Len() int64 {
+	return b.total
+}
+
+// String returns the kept output. If output was dropped, a marker with the
+// number of bytes and the file with the full output separates the head and
+// the tail.
+func (b *outputBuffer) String() string {
+	tail := b.tail
+	if b.max > 0 && len(tail) > b.tailSize() {
+		tail = tail[len(tail)-b.tailSize():]
+	}
+	dropped := b.total - int64(len(b.head)) - int64(len(tail))
+	if dropped <= 0 {
+		return string(b.head) + string(tail)
+	}
+	marker := fmt.Sprintf("\n[truncated %d bytes]\n", dropped)
+	if b.spill != nil {
+		marker = fmt.Sprintf("\n[truncated %d bytes, the full output is in the resource %s%s]\n", dropped, outputURIPrefix, filepath.Base(b.spill.Name()))
+	}
+	return string(b.head) + marker + string(tail)
+}
+
+// Close closes the file with the full output, which is removed after
+// spillRetention.
+func (b *outputBuffer) Close() error {
+	if b.spill == nil {
+		return nil
+	}
+	name := filepath.Base(b.spill.Name())
+	time.AfterFunc(spillRetention, func() { spilledOutputs.remove(name) })
+	return b.spill.Close()
+}
+
+// remove deletes the file with the full output right away.
+func (b *outputBuffer) remove() {
+	if b.spill == nil {
+		return
+	}
+	b.spill.Close()
+	spilledOutputs.remove(filepath.Base(b.spill.Name()))
+}
+
+// checkMaxOutputBytes rejects a negative output limit.
+func checkMaxOutputBytes(max int) error {
+	if max < 0 {
+		return fmt.Errorf("maxOutputBytes must not be negative")
+	}
+	return nil
+}
+
+// outputSettings returns the output limit and spilling of an item, which
+// override the global settings of s.
+func (s *Spec) outputSettings(max int, spill *bool) (int, *bool) {
+	if max == 0 {
+		max = s.MaxOutputBytes
+	}
+	if spill == nil {
+		global := s.SpillOutput
+		spill = &global
+	}
+	return max, spill
+}
+
+// newItemOutput returns the buffer for the output of item.
+func newItemOutput(item ContextItem) *outputBuffer {
+	var spillTo *spillOwner
+	if item.SpillOutput != nil && *item.SpillOutput {
+		spillTo = &spillOwner{tool: item.Name, resource: item.resourceURI}
+	}
+	return newOutputBuffer(item.MaxOutputBytes, spillTo)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	b := newOutputBuffer(10, nil)
+	b.Write([]byte("short"))
+	if got := b.String(); got != "short" {
+		t.Errorf("expected the output to be kept, got %q", got)
+	}
+
+	// Small writes exercise the trimming of the tail.
+	b = newOutputBuffer(10, nil)
+	for _, c := range "0123456789abcdefghijklmnopqrstuvwxyz" {
+		b.Write([]byte(string(c)))
+	}
+	if want := "01234\n[truncated 26 bytes]\nvwxyz"; b.String() != want {
+		t.Errorf("expected %q, got %q", want, b.String())
+	}
+	if b.Len() != 36 {
+		t.Errorf("expected 36 bytes written, got %d", b.Len())
+	}
+
+	b = newOutputBuffer(0, nil)
+	b.Write([]byte(strings.Repeat("x", 100000)))
+	if len(b.String()) != 100000 {
+		t.Errorf("expected no limit, got %d bytes", len(b.String()))
+	}
+}
+
+func TestExecuteCommand_MaxOutputBytes(t *testing.T) {
+	spill := true
+	item := ContextItem{Command: "seq 1 10000", MaxOutputBytes: 20}
+	output, _, _, err := executeCommand(context.Background(), item, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "1\n2\n3\n4\n5\n\n[truncated 48874 bytes]\n999\n10000\n"; output != want {
+		t.Errorf("expected %q, got %q", want, output)
+	}
+
+	// The full output is kept outside of the scratch space and readable by
+	// the callers allowed to use the tool.
+	outputs := spilledOutputs
+	defer func() { spilledOutputs = outputs }()
+	spilledOutputs = &spillStore{dir: t.TempDir(), owners: make(map[string]spillOwner)}
+	item.Name = "Logs"
+	item.SpillOutput = &spill
+	scratch := t.TempDir()
+	output, _, _, err = executeCommand(context.Background(), item, nil, scratch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	match := regexp.MustCompile(`truncated 48874 bytes, the full output is in the resource simple-mcp://output/(output-\d+\.txt)`).FindStringSubmatch(output)
+	if match == nil {
+		t.Fatalf("expected a reference to the full output, got %q", output)
+	}
+	data, err := spilledOutputs.read(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 48894 || !strings.HasSuffix(data, "\n9999\n10000\n") {
+		t.Errorf("expected the full output in %s, got %d bytes", match[1], len(data))
+	}
+	if entries, _ := os.ReadDir(scratch); len(entries) != 0 {
+		t.Errorf("expected nothing in the scratch space, got %v", entries)
+	}
+	policy := NewPolicy(&Config{Specification: Spec{
+		Roles: []RoleDefinition{{Name: "admin", Members: []string{"alice"}}},
+		Tools: []ContextItem{{Name: "Logs", Roles: []string{"admin"}}},
+	}}, NewTaskStore(10))
+	uri := outputURIPrefix + match[1]
+	if !policy.CanReadResource(withPrincipal(context.Background(), &Principal{Name: "alice"}), uri) || policy.CanReadResource(context.Background(), uri) {
+		t.Errorf("expected only callers of the tool to read %s", uri)
+	}
+
+	// The full output of a task is removed with the task.
+	taskStore := NewTaskStore(10)
+	task := taskStore.Create("task-1", "Logs")
+	task.Output.limit(item)
+	task.Output.Write([]byte(strings.Repeat("x", 100)))
+	if len(spilledOutputs.owners) != 2 {
+		t.Fatalf("expected the output of the task to be kept, got %v", spilledOutputs.owners)
+	}
+	taskStore.Delete("task-1")
+	if len(spilledOutputs.owners) != 1 {
+		t.Errorf("expected only the output of the tool to be kept, got %v", spilledOutputs.owners)
+	}
+}
+
+func TestLoadConfig_MaxOutputBytes(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  maxOutputBytes: 1000
+  spillOutput: true
+  tools:
+    - name: Default
+      command: "true"
+    - name: Own
+      command: "true"
+      maxOutputBytes: 50
+      spillOutput: false
+  resources:
+    - uri: "test://log"
+      command: "true"
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tools := cfg.Specification.Tools
+	if tools[0].MaxOutputBytes != 1000 || !*tools[0].SpillOutput {
+		t.Errorf("expected the global settings, got %d, %v", tools[0].MaxOutputBytes, *tools[0].SpillOutput)
+	}
+	if tools[1].MaxOutputBytes != 50 || *tools[1].SpillOutput {
+		t.Errorf("expected the settings of the tool, got %d, %v", tools[1].MaxOutputBytes, *tools[1].SpillOutput)
+	}
+	if item := cfg.Specification.Resources[0].commandItem(); item.MaxOutputBytes != 1000 || !*item.SpillOutput {
+		t.Errorf("expected the global settings for the resource, got %d, %v", item.MaxOutputBytes, *item.SpillOutput)
+	}
+
+	path = writeTestFile(t, t.TempDir(), "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Negative
+      command: "true"
+      maxOutputBytes: -1
+`)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "tool Negative: maxOutputBytes must not be negative") {
+		t.Errorf("expected a negative limit to be rejected, got %v", err)
+	}
+}
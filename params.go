@@ -0,0 +1,407 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides typed tool parameters. Each parameter declared in the
+// configuration is advertised to the LLM as a JSON Schema property, and the
+// arguments of every tool call are validated against it before any command is
+// executed.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported parameter types.
+const (
+	ParamTypeString  = "string"
+	ParamTypeInteger = "integer"
+	ParamTypeNumber  = "number"
+	ParamTypeBoolean = "boolean"
+	ParamTypeEnum    = "enum"
+	ParamTypeArray   = "array"
+)
+
+// ToolParameter describes a single argument accepted by a tool. In the YAML
+// file it can be written either as a bare name, which declares a required
+// string, or as a mapping with a type, description and constraints.
+type ToolParameter struct {
+	Name        string      `yaml:"name"`
+	Type        string      `yaml:"type,omitempty"`
+	Description string      `yaml:"description,omitempty"`
+	Default     interface{} `yaml:"default,omitempty"`
+	Optional    bool        `yaml:"optional,omitempty"`
+	Enum        []string    `yaml:"enum,omitempty"`
+	Items       string      `yaml:"items,omitempty"`
+	Pattern     string      `yaml:"pattern,omitempty"`
+	Minimum     *float64    `yaml:"minimum,omitempty"`
+	Maximum     *float64    `yaml:"maximum,omitempty"`
+	MinLength   *int        `yaml:"minLength,omitempty"`
+	MaxLength   *int        `yaml:"maxLength,omitempty"`
+	Secret      bool        `yaml:"secret,omitempty"` // Redact the value in the audit log
+
+	pattern *regexp.Regexp
+}
+
+// UnmarshalYAML accepts both the legacy `parameters: ["name"]` form and the
+// full mapping form.
+func (p *ToolParameter) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = ToolParameter{Name: value.Value}
+		return nil
+	}
+	type plain ToolParameter
+	var raw plain
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*p = ToolParameter(raw)
+	return nil
+}
+
+// paramType returns the declared type, defaulting to string.
+func (p *ToolParameter) paramType() string {
+	if p.Type == "" {
+		if len(p.Enum) > 0 {
+			return ParamTypeEnum
+		}
+		return ParamTypeString
+	}
+	return p.Type
+}
+
+// itemType returns the element type of an array parameter, defaulting to string.
+func (p *ToolParameter) itemType() string {
+	if p.Items == "" {
+		return ParamTypeString
+	}
+	return p.Items
+}
+
+// required reports whether the caller must supply the parameter.
+func (p *ToolParameter) required() bool {
+	return !p.Optional && p.Default == nil
+}
+
+// compile checks the parameter definition for consistency and prepares it for
+// validating arguments. It is called once by LoadConfig.
+func (p *ToolParameter) compile() error {
+	if p.Name == "" {
+		return fmt.Errorf("parameter has no name")
+	}
+	switch p.paramType() {
+	case ParamTypeString, ParamTypeInteger, ParamTypeNumber, ParamTypeBoolean:
+	case ParamTypeEnum:
+		if len(p.Enum) == 0 {
+			return fmt.Errorf("parameter %s: type 'enum' requires a non-empty 'enum' list", p.Name)
+		}
+	case ParamTypeArray:
+		switch p.itemType() {
+		case ParamTypeString, ParamTypeInteger, ParamTypeNumber, ParamTypeBoolean:
+		default:
+			return fmt.Errorf("parameter %s: unsupported array item type '%s'", p.Name, p.Items)
+		}
+	default:
+		return fmt.Errorf("parameter %s: unsupported type '%s'", p.Name, p.Type)
+	}
+
+	if p.Pattern != "" {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return fmt.Errorf("parameter %s: invalid pattern: %v", p.Name, err)
+		}
+		p.pattern = re
+	}
+
+	if p.Minimum != nil && p.Maximum != nil && *p.Minimum > *p.Maximum {
+		return fmt.Errorf("parameter %s: minimum is greater than maximum", p.Name)
+	}
+
+	if p.Default != nil {
+		normalized, err := p.convert(p.Default)
+		if err != nil {
+			return fmt.Errorf("parameter %s: invalid default: %v", p.Name, err)
+		}
+		p.Default = normalized
+	}
+	return nil
+}
+
+// toolOption returns the mcp-go option advertising the parameter's schema.
+func (p *ToolParameter) toolOption() mcp.ToolOption {
+	description := p.Description
+	if description == "" {
+		description = fmt.Sprintf("Parameter: %s", p.Name)
+	}
+
+	opts := []mcp.PropertyOption{mcp.Description(description)}
+	if p.required() {
+		opts = append(opts, mcp.Required())
+	}
+	if p.Default != nil {
+		opts = append(opts, func(schema map[string]any) {
+			schema["default"] = p.Default
+		})
+	}
+	opts = append(opts, p.constraintOptions(p.paramType())...)
+
+	switch p.paramType() {
+	case ParamTypeInteger:
+		return mcp.WithNumber(p.Name, append(opts, func(schema map[string]any) {
+			schema["type"] = "integer"
+		})...)
+	case ParamTypeNumber:
+		return mcp.WithNumber(p.Name, opts...)
+	case ParamTypeBoolean:
+		return mcp.WithBoolean(p.Name, opts...)
+	case ParamTypeEnum:
+		return mcp.WithString(p.Name, opts...)
+	case ParamTypeArray:
+		items := map[string]any{"type": p.itemType()}
+		for _, opt := range p.constraintOptions(p.itemType()) {
+			opt(items)
+		}
+		return mcp.WithArray(p.Name, append(opts, mcp.Items(items))...)
+	default:
+		return mcp.WithString(p.Name, opts...)
+	}
+}
+
+// constraintOptions returns the schema constraints that apply to values of
+// the given type. For arrays, the constraints apply to the individual items.
+func (p *ToolParameter) constraintOptions(typ string) []mcp.PropertyOption {
+	var opts []mcp.PropertyOption
+	switch typ {
+	case ParamTypeString, ParamTypeEnum:
+		if len(p.Enum) > 0 {
+			opts = append(opts, mcp.Enum(p.Enum...))
+		}
+		if p.Pattern != "" {
+			opts = append(opts, mcp.Pattern(p.Pattern))
+		}
+		if p.MinLength != nil {
+			opts = append(opts, mcp.MinLength(*p.MinLength))
+		}
+		if p.MaxLength != nil {
+			opts = append(opts, mcp.MaxLength(*p.MaxLength))
+		}
+	case ParamTypeInteger, ParamTypeNumber:
+		if p.Minimum != nil {
+			opts = append(opts, mcp.Min(*p.Minimum))
+		}
+		if p.Maximum != nil {
+			opts = append(opts, mcp.Max(*p.Maximum))
+		}
+	}
+	return opts
+}
+
+// convert coerces a raw argument into the parameter's type and checks its
+// constraints. String representations of numbers and booleans are accepted,
+// since simple clients (including simple-mcp-cli) send every argument as a
+// string.
+func (p *ToolParameter) convert(value interface{}) (interface{}, error) {
+	if p.paramType() != ParamTypeArray {
+		return p.convertScalar(p.paramType(), value)
+	}
+
+	var items []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case []string:
+		for _, s := range v {
+			items = append(items, s)
+		}
+	case string:
+		if err := json.Unmarshal([]byte(v), &items); err != nil {
+			return nil, fmt.Errorf("expected an array")
+		}
+	default:
+		return nil, fmt.Errorf("expected an array")
+	}
+
+	result := make([]interface{}, 0, len(items))
+	for i, item := range items {
+		converted, err := p.convertScalar(p.itemType(), item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+func (p *ToolParameter) convertScalar(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case ParamTypeString, ParamTypeEnum:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string")
+		}
+		if typ == ParamTypeEnum || len(p.Enum) > 0 {
+			found := false
+			for _, allowed := range p.Enum {
+				if s == allowed {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("must be one of: %s", strings.Join(p.Enum, ", "))
+			}
+		}
+		if p.MinLength != nil && utf8.RuneCountInString(s) < *p.MinLength {
+			return nil, fmt.Errorf("must be at least %d characters long", *p.MinLength)
+		}
+		if p.MaxLength != nil && utf8.RuneCountInString(s) > *p.MaxLength {
+			return nil, fmt.Errorf("must be at most %d characters long", *p.MaxLength)
+		}
+		if p.Pattern != "" {
+			re := p.pattern
+			if re == nil {
+				var err error
+				if re, err = regexp.Compile(p.Pattern); err != nil {
+					return nil, fmt.Errorf("invalid pattern: %v", err)
+				}
+			}
+			if !re.MatchString(s) {
+				return nil, fmt.Errorf("does not match pattern %s", p.Pattern)
+			}
+		}
+		return s, nil
+
+	case ParamTypeInteger, ParamTypeNumber:
+		var f float64
+		switch v := value.(type) {
+		case float64:
+			f = v
+		case int:
+			f = float64(v)
+		case int64:
+			f = float64(v)
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected a %s", typ)
+			}
+			f = parsed
+		default:
+			return nil, fmt.Errorf("expected a %s", typ)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("expected a finite %s", typ)
+		}
+		if typ == ParamTypeInteger && f != math.Trunc(f) {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		if p.Minimum != nil && f < *p.Minimum {
+			return nil, fmt.Errorf("must be at least %s", formatParamValue(*p.Minimum))
+		}
+		if p.Maximum != nil && f > *p.Maximum {
+			return nil, fmt.Errorf("must be at most %s", formatParamValue(*p.Maximum))
+		}
+		if typ == ParamTypeInteger {
+			return int64(f), nil
+		}
+		return f, nil
+
+	case ParamTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("expected a boolean")
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean")
+	}
+	return nil, fmt.Errorf("unsupported type '%s'", typ)
+}
+
+// ParameterError reports an argument that failed validation.
+type ParameterError struct {
+	Parameter string
+	Message   string
+}
+
+func (e *ParameterError) Error() string {
+	return fmt.Sprintf("invalid value for parameter '%s': %s", e.Parameter, e.Message)
+}
+
+// toolResult converts the error into a tool error result that also carries
+// the failing parameter as structured content.
+func (e *ParameterError) toolResult() *mcp.CallToolResult {
+	result := mcp.NewToolResultError(e.Error())
+	result.StructuredContent = map[string]any{
+		"error":     "invalid_argument",
+		"parameter": e.Parameter,
+		"message":   e.Message,
+	}
+	return result
+}
+
+// validateArguments checks the arguments of a tool call against the declared
+// parameters and returns the normalized values to be passed to executeCommand.
+// Defaults are filled in for missing arguments; optional parameters without a
+// default are passed as empty strings.
+func validateArguments(params []ToolParameter, args map[string]any) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(params))
+	for i := range params {
+		p := &params[i]
+		value, ok := args[p.Name]
+		if !ok || value == nil {
+			switch {
+			case p.Default != nil:
+				result[p.Name] = p.Default
+			case p.Optional:
+				result[p.Name] = ""
+			default:
+				return nil, &ParameterError{Parameter: p.Name, Message: "required parameter is missing"}
+			}
+			continue
+		}
+
+		converted, err := p.convert(value)
+		if err != nil {
+			return nil, &ParameterError{Parameter: p.Name, Message: err.Error()}
+		}
+		result[p.Name] = converted
+	}
+	return result, nil
+}
+
+// formatParamValue renders a validated argument as the string passed to the
+// command. Array elements are joined with newlines so that they can be
+// iterated over in the shell.
+func formatParamValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatParamValue(item)
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestToolParameter_UnmarshalYAML(t *testing.T) {
+	data := `
+- serviceName
+- name: lines
+  type: integer
+  description: "Number of lines"
+  default: 10
+  minimum: 1
+  maximum: 1000
+`
+	var params []ToolParameter
+	if err := yaml.Unmarshal([]byte(data), &params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(params) != 2 {
+		t.Fatalf("expected 2 parameters, got %d", len(params))
+	}
+	if params[0].Name != "serviceName" || params[0].paramType() != ParamTypeString || !params[0].required() {
+		t.Errorf("bare name should declare a required string, got %+v", params[0])
+	}
+	if params[1].Name != "lines" || params[1].Type != ParamTypeInteger || params[1].required() {
+		t.Errorf("unexpected mapping parameter: %+v", params[1])
+	}
+	if err := params[1].compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if params[1].Default != int64(10) {
+		t.Errorf("expected default to be normalized to int64(10), got %#v", params[1].Default)
+	}
+}
+
+func TestToolParameter_Compile(t *testing.T) {
+	min, max := 10.0, 1.0
+	tests := []struct {
+		name  string
+		param ToolParameter
+	}{
+		{"Unknown type", ToolParameter{Name: "a", Type: "object"}},
+		{"Enum without values", ToolParameter{Name: "a", Type: ParamTypeEnum}},
+		{"Bad pattern", ToolParameter{Name: "a", Pattern: "["}},
+		{"Bad array items", ToolParameter{Name: "a", Type: ParamTypeArray, Items: "object"}},
+		{"Minimum above maximum", ToolParameter{Name: "a", Type: ParamTypeNumber, Minimum: &min, Maximum: &max}},
+		{"Default of wrong type", ToolParameter{Name: "a", Type: ParamTypeBoolean, Default: "maybe"}},
+		{"No name", ToolParameter{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.param.compile(); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestValidateArguments(t *testing.T) {
+	min, max := 1.0, 100.0
+	maxLen := 5
+	params := []ToolParameter{
+		{Name: "unit", Pattern: `^[a-z]+\.service$`},
+		{Name: "count", Type: ParamTypeInteger, Minimum: &min, Maximum: &max, Default: 10},
+		{Name: "ratio", Type: ParamTypeNumber, Optional: true},
+		{Name: "follow", Type: ParamTypeBoolean, Optional: true},
+		{Name: "mode", Type: ParamTypeEnum, Enum: []string{"fast", "slow"}, Optional: true},
+		{Name: "tag", MaxLength: &maxLen, Optional: true},
+		{Name: "hosts", Type: ParamTypeArray, Optional: true},
+	}
+	for i := range params {
+		if err := params[i].compile(); err != nil {
+			t.Fatalf("compile failed: %v", err)
+		}
+	}
+
+	t.Run("Valid with defaults", func(t *testing.T) {
+		result, err := validateArguments(params, map[string]any{"unit": "sshd.service"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result["unit"] != "sshd.service" {
+			t.Errorf("unexpected unit: %v", result["unit"])
+		}
+		if result["count"] != int64(10) {
+			t.Errorf("expected default count 10, got %#v", result["count"])
+		}
+		if result["ratio"] != "" {
+			t.Errorf("expected optional parameter to be empty, got %#v", result["ratio"])
+		}
+	})
+
+	t.Run("Coerces strings", func(t *testing.T) {
+		result, err := validateArguments(params, map[string]any{
+			"unit":   "sshd.service",
+			"count":  "42",
+			"ratio":  "0.5",
+			"follow": "true",
+			"hosts":  `["a", "b"]`,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result["count"] != int64(42) || result["ratio"] != 0.5 || result["follow"] != true {
+			t.Errorf("unexpected coercion result: %#v", result)
+		}
+		if formatParamValue(result["hosts"]) != "a\nb" {
+			t.Errorf("unexpected array rendering: %q", formatParamValue(result["hosts"]))
+		}
+	})
+
+	failures := []struct {
+		name  string
+		args  map[string]any
+		param string
+	}{
+		{"Missing required", map[string]any{}, "unit"},
+		{"Pattern mismatch", map[string]any{"unit": "sshd; reboot"}, "unit"},
+		{"Wrong type", map[string]any{"unit": "a.service", "count": true}, "count"},
+		{"Not an integer", map[string]any{"unit": "a.service", "count": 1.5}, "count"},
+		{"Below minimum", map[string]any{"unit": "a.service", "count": 0.0}, "count"},
+		{"Above maximum", map[string]any{"unit": "a.service", "count": "101"}, "count"},
+		{"Not in enum", map[string]any{"unit": "a.service", "mode": "medium"}, "mode"},
+		{"Too long", map[string]any{"unit": "a.service", "tag": "toolong"}, "tag"},
+		{"Not an array", map[string]any{"unit": "a.service", "hosts": 5.0}, "hosts"},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateArguments(params, tt.args)
+			paramErr, ok := err.(*ParameterError)
+			if !ok {
+				t.Fatalf("expected *ParameterError, got %v", err)
+			}
+			if paramErr.Parameter != tt.param {
+				t.Errorf("expected failing parameter %s, got %s", tt.param, paramErr.Parameter)
+			}
+			result := paramErr.toolResult()
+			if !result.IsError {
+				t.Error("expected an error result")
+			}
+			structured, _ := result.StructuredContent.(map[string]any)
+			if structured["parameter"] != tt.param {
+				t.Errorf("expected structured content to name %s, got %v", tt.param, structured)
+			}
+		})
+	}
+}
+
+func TestToolParameter_Schema(t *testing.T) {
+	min := 1.0
+	params := []ToolParameter{
+		{Name: "unit", Description: "The systemd unit", Pattern: "^[a-z]+$"},
+		{Name: "lines", Type: ParamTypeInteger, Minimum: &min, Default: 10},
+		{Name: "mode", Type: ParamTypeEnum, Enum: []string{"a", "b"}, Optional: true},
+		{Name: "hosts", Type: ParamTypeArray, Items: ParamTypeString, Optional: true},
+	}
+	var opts []mcp.ToolOption
+	for i := range params {
+		if err := params[i].compile(); err != nil {
+			t.Fatalf("compile failed: %v", err)
+		}
+		opts = append(opts, params[i].toolOption())
+	}
+	tool := mcp.NewTool("Test", opts...)
+
+	data, err := json.Marshal(tool.InputSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := string(data)
+	for _, expected := range []string{
+		`"required":["unit"]`,
+		`"description":"The systemd unit"`,
+		`"pattern":"^[a-z]+$"`,
+		`"type":"integer"`,
+		`"minimum":1`,
+		`"default":10`,
+		`"enum":["a","b"]`,
+		`"items":{"type":"string"}`,
+	} {
+		if !strings.Contains(schema, expected) {
+			t.Errorf("expected schema to contain %s, got %s", expected, schema)
+		}
+	}
+}
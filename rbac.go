@@ -0,0 +1,352 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides role-based access control for tools and resources.
+// Roles are defined in the configuration together with the principals that
+// hold them, and each tool or resource can list the roles allowed to use it.
+// Items without roles are open to every caller, or to none with
+// defaultAccess: deny. The checks run as mcp-go middleware, before any
+// handler is invoked.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/yosida95/uritemplate/v3"
+)
+
+// builtinTools are the names of the tools provided by the server itself, which
+// can be given roles in builtinToolRoles.
+var builtinTools = []string{
+	"ListPendingTasks", "TaskStatus", "CancelTask", "ListResources", "GetResource", "SearchResources",
+	"CreateFile", "ReadFile", "DeleteFile", "ReplaceInFile", "ListDirectory", "CreateDirectory",
+	"RemoveDirectory", "CopyResourceToFile", "CopyResourceTree",
+}
+
+// RoleDefinition names a role and the principals that hold it.
+type RoleDefinition struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description,omitempty"`
+	Members     []string `yaml:"members,omitempty"`
+}
+
+// Policy decides which tools, resources and tasks a caller may access. A nil
+// *Policy allows everything, which is the behavior when no roles are defined.
+type Policy struct {
+	members       map[string]map[string]bool // Principal name -> set of roles
+	defined       map[string]bool
+	defaultRole   string
+	defaultDeny   bool // Items without roles are denied to every caller
+	toolRoles     map[string][]string
+	resourceRoles map[string][]string
+	dirRoles      map[string][]string // URI prefix of the files of a directory -> roles
+	templateRoles []templateRoles
+	taskStore     TaskStore
+}
+
+// templateRoles are the roles allowed to read the resources matching a URI
+// template.
+type templateRoles struct {
+	template *uritemplate.Template
+	roles    []string
+}
+
+// NewPolicy builds the access policy from the roles in cfg. It returns nil if
+// the configuration does not define any roles.
+func NewPolicy(cfg *Config, taskStore TaskStore) *Policy {
+	if len(cfg.Specification.Roles) == 0 {
+		return nil
+	}
+
+	p := &Policy{
+		members:       make(map[string]map[string]bool),
+		defined:       make(map[string]bool),
+		defaultRole:   cfg.Specification.DefaultRole,
+		defaultDeny:   cfg.Specification.DefaultAccess == "deny",
+		toolRoles:     make(map[string][]string),
+		resourceRoles: make(map[string][]string),
+		dirRoles:      make(map[string][]string),
+		taskStore:     taskStore,
+	}
+	for _, role := range cfg.Specification.Roles {
+		p.defined[role.Name] = true
+		for _, member := range role.Members {
+			if p.members[member] == nil {
+				p.members[member] = make(map[string]bool)
+			}
+			p.members[member][role.Name] = true
+		}
+	}
+	for name, roles := range cfg.Specification.BuiltinToolRoles {
+		if len(roles) > 0 {
+			p.toolRoles[name] = roles
+		}
+	}
+	for _, tool := range cfg.Specification.Tools {
+		if len(tool.Roles) > 0 {
+			p.toolRoles[tool.Name] = tool.Roles
+		}
+	}
+	for _, resource := range cfg.Specification.Resources {
+		if len(resource.Roles) > 0 && resource.URITemplate != "" {
+			// Checked by LoadConfig.
+			template := uritemplate.MustNew(resource.URITemplate)
+			p.templateRoles = append(p.templateRoles, templateRoles{template, resource.Roles})
+		} else if len(resource.Roles) > 0 {
+			p.resourceRoles[resource.URI] = resource.Roles
+			if resource.Directory != "" {
+				p.dirRoles[directoryResourceURI(resource.URI, "")] = resource.Roles
+			}
+		}
+	}
+	return p
+}
+
+// validateRoles checks that the role definitions are well-formed and that
+// tools and resources only refer to defined roles.
+func validateRoles(spec *Spec) error {
+	defined := make(map[string]bool)
+	for _, role := range spec.Roles {
+		if role.Name == "" {
+			return fmt.Errorf("role without a name")
+		}
+		if defined[role.Name] {
+			return fmt.Errorf("role %s is defined more than once", role.Name)
+		}
+		defined[role.Name] = true
+	}
+
+	check := func(what string, roles []string) error {
+		for _, role := range roles {
+			if !defined[role] {
+				return fmt.Errorf("%s refers to undefined role %s", what, role)
+			}
+		}
+		return nil
+	}
+	if spec.DefaultRole != "" {
+		if err := check("defaultRole", []string{spec.DefaultRole}); err != nil {
+			return err
+		}
+	}
+	if spec.DefaultAccess != "" && spec.DefaultAccess != "allow" && spec.DefaultAccess != "deny" {
+		return fmt.Errorf("invalid defaultAccess %q, must be allow or deny", spec.DefaultAccess)
+	}
+	for name, roles := range spec.BuiltinToolRoles {
+		if !slices.Contains(builtinTools, name) {
+			return fmt.Errorf("builtinToolRoles refers to unknown built-in tool %s", name)
+		}
+		if err := check("built-in tool "+name, roles); err != nil {
+			return err
+		}
+	}
+	for _, tool := range spec.Tools {
+		if err := check("tool "+tool.Name, tool.Roles); err != nil {
+			return err
+		}
+	}
+	for _, resource := range spec.Resources {
+		if err := check("resource "+resource.URI, resource.Roles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callerRoles returns the set of roles held by the caller of the current
+// request: the roles the principal is a member of, the roles carried in its
+// token, and the default role.
+func (p *Policy) callerRoles(ctx context.Context) map[string]bool {
+	roles := make(map[string]bool)
+	if p.defaultRole != "" {
+		roles[p.defaultRole] = true
+	}
+	principal := PrincipalFromContext(ctx)
+	if principal == nil {
+		return roles
+	}
+	for role := range p.members[principal.Name] {
+		roles[role] = true
+	}
+	for _, role := range principal.Roles {
+		if p.defined[role] {
+			roles[role] = true
+		}
+	}
+	return roles
+}
+
+// allowed reports whether the caller holds one of the given roles. Items
+// without roles are available to every caller, unless the default is to deny
+// access.
+func (p *Policy) allowed(ctx context.Context, itemRoles []string) bool {
+	if p == nil {
+		return true
+	}
+	if len(itemRoles) == 0 {
+		return !p.defaultDeny
+	}
+	callerRoles := p.callerRoles(ctx)
+	for _, role := range itemRoles {
+		if callerRoles[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUseTool reports whether the caller may call the named tool.
+func (p *Policy) CanUseTool(ctx context.Context, name string) bool {
+	if p == nil {
+		return true
+	}
+	return p.allowed(ctx, p.toolRoles[name])
+}
+
+// CanReadResource reports whether the caller may read the resource at uri.
+// Task resources are readable by callers allowed to use the tool that
+// started the task.
+func (p *Policy) CanReadResource(ctx context.Context, uri string) bool {
+	if p == nil {
+		return true
+	}
+	if taskID, ok := strings.CutPrefix(uri, "simple-mcp://tasks/"); ok {
+		return p.CanAccessTask(ctx, taskID)
+	}
+	if name, ok := strings.CutPrefix(uri, outputURIPrefix); ok {
+		return p.canReadOutput(ctx, name)
+	}
+	if roles, ok := p.resourceRoles[uri]; ok {
+		return p.allowed(ctx, roles)
+	}
+	matched := false
+	for prefix, roles := range p.dirRoles {
+		if strings.HasPrefix(uri, prefix) {
+			if !p.allowed(ctx, roles) {
+				return false
+			}
+			matched = true
+		}
+	}
+	for _, t := range p.templateRoles {
+		if t.template.Match(uri) != nil {
+			if !p.allowed(ctx, t.roles) {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched || p.allowed(ctx, nil)
+}
+
+// canReadOutput reports whether the caller may read the full output of a
+// truncated command, which requires access to the tool or resource running
+// the command.
+func (p *Policy) canReadOutput(ctx context.Context, name string) bool {
+	owner, ok := spilledOutputs.owner(name)
+	if !ok {
+		return true // Nothing to protect; the caller gets "not found".
+	}
+	if owner.tool != "" {
+		return p.CanUseTool(ctx, owner.tool)
+	}
+	return p.CanReadResource(ctx, owner.resource)
+}
+
+// CanAccessTask reports whether the caller may see or cancel a task.
+func (p *Policy) CanAccessTask(ctx context.Context, taskID string) bool {
+	if p == nil {
+		return true
+	}
+	task, ok := p.taskStore.Get(taskID)
+	if !ok {
+		return true // Nothing to protect; the caller gets "not found".
+	}
+	return p.CanUseTool(ctx, task.ToolName)
+}
+
+// visibleResources returns the subset of resourceMap the caller may read.
+func (p *Policy) visibleResources(ctx context.Context, resourceMap map[string]ResourceItem) map[string]ResourceItem {
+	if p == nil {
+		return resourceMap
+	}
+	visible := make(map[string]ResourceItem, len(resourceMap))
+	for uri, item := range resourceMap {
+		if p.allowed(ctx, item.Roles) {
+			visible[uri] = item
+		}
+	}
+	return visible
+}
+
+// policyServerOptions returns the mcp-go options that enforce the policy
+// returned by current: tools the caller may not use are hidden from
+// tools/list, and calls and reads are rejected before they reach the
+// handlers. The policy is looked up on every request, so it can be replaced
+// when the configuration is reloaded.
+func policyServerOptions(current func() *Policy) []server.ServerOption {
+	return []server.ServerOption{
+		server.WithToolFilter(func(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+			return current().filterTools(ctx, tools)
+		}),
+		server.WithToolHandlerMiddleware(func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+			return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				return current().toolMiddleware(next)(ctx, request)
+			}
+		}),
+		server.WithResourceHandlerMiddleware(func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+			return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+				return current().resourceMiddleware(next)(ctx, request)
+			}
+		}),
+	}
+}
+
+func (p *Policy) filterTools(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	if p == nil {
+		return tools
+	}
+	var visible []mcp.Tool
+	for _, tool := range tools {
+		if p.CanUseTool(ctx, tool.Name) {
+			visible = append(visible, tool)
+		}
+	}
+	return visible
+}
+
+func (p *Policy) toolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if p == nil {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !p.CanUseTool(ctx, request.Params.Name) {
+			log.Printf("Denied call of tool %s to %s", request.Params.Name, principalName(ctx))
+			return mcp.NewToolResultError(fmt.Sprintf("Access denied: you are not allowed to use tool '%s'.", request.Params.Name)), nil
+		}
+		return next(ctx, request)
+	}
+}
+
+func (p *Policy) resourceMiddleware(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+	if p == nil {
+		return next
+	}
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		if !p.CanReadResource(ctx, request.Params.URI) {
+			log.Printf("Denied read of resource %s to %s", request.Params.URI, principalName(ctx))
+			return nil, fmt.Errorf("access denied to resource %s", request.Params.URI)
+		}
+		return next(ctx, request)
+	}
+}
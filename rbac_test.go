@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestPolicy(t *testing.T) {
+	cfg := &Config{Specification: Spec{
+		Roles: []RoleDefinition{
+			{Name: "readonly"},
+			{Name: "operator", Members: []string{"bob"}},
+			{Name: "admin", Members: []string{"alice", "CN=root,O=Example"}},
+		},
+		DefaultRole: "readonly",
+		Tools: []ContextItem{
+			{Name: "GetLogs", Roles: []string{"readonly", "operator", "admin"}},
+			{Name: "RestartService", Roles: []string{"operator", "admin"}},
+			{Name: "Reboot", Roles: []string{"admin"}},
+			{Name: "Uptime"},
+		},
+		Resources: []ResourceItem{
+			{URI: "test://public"},
+			{URI: "test://secret", Roles: []string{"admin"}},
+			{URI: "test://logs", Directory: "/var/log", Roles: []string{"admin"}},
+		},
+	}}
+	taskStore := NewTaskStore(10)
+	taskStore.Create("task-1", "Reboot")
+	policy := NewPolicy(cfg, taskStore)
+
+	as := func(name string, roles ...string) context.Context {
+		if name == "" {
+			return context.Background()
+		}
+		return withPrincipal(context.Background(), &Principal{Name: name, Roles: roles})
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		allowed []string
+		denied  []string
+	}{
+		{"Anonymous", as(""), []string{"GetLogs", "Uptime", "ListPendingTasks"}, []string{"RestartService", "Reboot"}},
+		{"Operator", as("bob"), []string{"GetLogs", "RestartService"}, []string{"Reboot"}},
+		{"Admin", as("alice"), []string{"GetLogs", "RestartService", "Reboot"}, nil},
+		{"Certificate subject", as("CN=root,O=Example"), []string{"Reboot"}, nil},
+		{"Token roles", as("carol", "operator", "unknown"), []string{"RestartService"}, []string{"Reboot"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, tool := range tt.allowed {
+				if !policy.CanUseTool(tt.ctx, tool) {
+					t.Errorf("expected %s to be allowed", tool)
+				}
+			}
+			for _, tool := range tt.denied {
+				if policy.CanUseTool(tt.ctx, tool) {
+					t.Errorf("expected %s to be denied", tool)
+				}
+			}
+		})
+	}
+
+	t.Run("Filter tools", func(t *testing.T) {
+		tools := policy.filterTools(as("bob"), []mcp.Tool{
+			mcp.NewTool("GetLogs"), mcp.NewTool("Reboot"), mcp.NewTool("ListResources"),
+		})
+		if len(tools) != 2 || tools[0].Name != "GetLogs" || tools[1].Name != "ListResources" {
+			t.Errorf("unexpected tools: %v", tools)
+		}
+	})
+
+	t.Run("Tool middleware", func(t *testing.T) {
+		called := false
+		handler := policy.toolMiddleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			called = true
+			return mcp.NewToolResultText("ok"), nil
+		})
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "Reboot"
+		result, err := handler(as("bob"), request)
+		if err != nil || !result.IsError || called {
+			t.Errorf("expected call to be rejected before the handler, got %v %v", result, err)
+		}
+		handler(as("alice"), request)
+		if !called {
+			t.Error("expected handler to be called for admin")
+		}
+	})
+
+	t.Run("Resources and tasks", func(t *testing.T) {
+		if !policy.CanReadResource(as("bob"), "test://public") || policy.CanReadResource(as("bob"), "test://secret") {
+			t.Error("unexpected resource access for operator")
+		}
+		if policy.CanReadResource(as("bob"), "test://logs/messages") || !policy.CanReadResource(as("alice"), "test://logs/messages") {
+			t.Error("files of a directory should follow the roles of the directory")
+		}
+		if policy.CanReadResource(as("bob"), "simple-mcp://tasks/task-1") || !policy.CanReadResource(as("alice"), "simple-mcp://tasks/task-1") {
+			t.Error("task access should follow the roles of the tool that started it")
+		}
+
+		resourceMap := map[string]ResourceItem{}
+		for _, item := range cfg.Specification.Resources {
+			resourceMap[item.URI] = item
+		}
+		if visible := policy.visibleResources(as("bob"), resourceMap); len(visible) != 1 {
+			t.Errorf("expected 1 visible resource, got %d", len(visible))
+		}
+	})
+
+	t.Run("No roles defined", func(t *testing.T) {
+		nilPolicy := NewPolicy(&Config{}, taskStore)
+		if nilPolicy != nil || !nilPolicy.CanUseTool(as(""), "Reboot") || !nilPolicy.CanReadResource(as(""), "test://secret") {
+			t.Error("expected everything to be allowed without roles")
+		}
+	})
+}
+
+func TestPolicy_DefaultDeny(t *testing.T) {
+	cfg := &Config{Specification: Spec{
+		Roles:            []RoleDefinition{{Name: "admin", Members: []string{"alice"}}},
+		DefaultAccess:    "deny",
+		BuiltinToolRoles: map[string][]string{"CreateFile": {"admin"}},
+		Tools:            []ContextItem{{Name: "Uptime"}, {Name: "Reboot", Roles: []string{"admin"}}},
+		Resources: []ResourceItem{
+			{URI: "test://public"},
+			{URI: "test://logs", Directory: "/var/log", Roles: []string{"admin"}},
+		},
+	}}
+	policy := NewPolicy(cfg, NewTaskStore(10))
+	alice := withPrincipal(context.Background(), &Principal{Name: "alice"})
+	bob := withPrincipal(context.Background(), &Principal{Name: "bob"})
+
+	for _, tool := range []string{"Uptime", "CreateFile", "DeleteFile", "CancelTask", "Reboot"} {
+		if policy.CanUseTool(bob, tool) {
+			t.Errorf("expected %s to be denied", tool)
+		}
+	}
+	if !policy.CanUseTool(alice, "CreateFile") || !policy.CanUseTool(alice, "Reboot") || policy.CanUseTool(alice, "DeleteFile") {
+		t.Error("expected only the tools with roles of alice to be allowed")
+	}
+	if policy.CanReadResource(alice, "test://public") || !policy.CanReadResource(alice, "test://logs/syslog") || policy.CanReadResource(bob, "test://logs/syslog") {
+		t.Error("expected only the resources with roles of alice to be readable")
+	}
+
+	cfg.Specification.BuiltinToolRoles = map[string][]string{"Reboot": {"admin"}}
+	if err := validateRoles(&cfg.Specification); err == nil {
+		t.Error("expected roles of an unknown built-in tool to be rejected")
+	}
+	cfg.Specification.BuiltinToolRoles = nil
+	cfg.Specification.DefaultAccess = "none"
+	if err := validateRoles(&cfg.Specification); err == nil {
+		t.Error("expected an invalid defaultAccess to be rejected")
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides hot reloading of the configuration file. When the file
+// changes or the server receives SIGHUP, the configuration is loaded again and
+// the tools and resources of the running server are updated to match it. A
+// configuration that fails to load is rejected and the old one stays live.
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// reloadDelay is how long the watcher waits for further changes before
+// reloading, as editors often write a file in several steps.
+const reloadDelay = 200 * time.Millisecond
+
+// Registry holds the tools, resources and access policy currently served.
+// The maps are replaced, never modified, so a map returned by a getter can be
+// used without holding the lock.
+type Registry struct {
+	mu        sync.RWMutex
+	tools     map[string]ContextItem
+	resources map[string]ResourceItem
+	policy    *Policy
+	taskStore TaskStore
+}
+
+// NewRegistry creates a registry holding the tools and resources of cfg.
+func NewRegistry(cfg *Config, taskStore TaskStore) *Registry {
+	r := &Registry{taskStore: taskStore}
+	r.set(cfg)
+	return r
+}
+
+func (r *Registry) set(cfg *Config) {
+	tools := make(map[string]ContextItem)
+	for _, item := range cfg.Specification.Tools {
+		tools[item.Name] = item
+	}
+	resources := make(map[string]ResourceItem)
+	for _, item := range cfg.Specification.Resources {
+		resources[item.id()] = item
+	}
+	policy := NewPolicy(cfg, r.taskStore)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools = tools
+	r.resources = resources
+	r.policy = policy
+}
+
+// Tools returns the tools from the configuration, keyed by name.
+func (r *Registry) Tools() map[string]ContextItem {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tools
+}
+
+// Resources returns the resources from the configuration, keyed by URI or
+// URI template.
+func (r *Registry) Resources() map[string]ResourceItem {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.resources
+}
+
+// Policy returns the current access policy, which is nil if no roles are
+// defined.
+func (r *Registry) Policy() *Policy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.policy
+}
+
+// visibleResources returns the resources the caller may read.
+func (r *Registry) visibleResources(ctx context.Context) map[string]ResourceItem {
+	return r.Policy().visibleResources(ctx, r.Resources())
+}
+
+// ServerOptions returns the mcp-go options enforcing the current policy.
+func (r *Registry) ServerOptions() []server.ServerOption {
+	return policyServerOptions(r.Policy)
+}
+
+// configReloader loads the configuration file again and applies the changes
+// to the running server.
+type configReloader struct {
+	path     string
+	server   *server.MCPServer
+	registry *Registry
+	opts     Options
+
+	// resolve computes the effective options of a configuration, which are
+	// compared with opts to warn about settings that need a restart.
+	resolve func(cfg *Config) Options
+
+	taskStore     TaskStore
+	subscriptions *Subscriptions
+	monitor       *ResourceMonitor
+	authenticator *Authenticator
+	auditLog      *AuditLog
+	tmpDir        string
+	verbose       bool
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	files   map[string]bool // Files of the current configuration
+}
+
+// reload loads the configuration and updates the tools and resources of the
+// server. If the configuration cannot be loaded, the error is returned and
+// nothing changes.
+func (r *configReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := LoadConfig(r.path)
+	if err != nil {
+		return err
+	}
+	if err := checkPrivileges(cfg); err != nil {
+		return err
+	}
+
+	if r.resolve(cfg) != r.opts {
+		log.Printf("WARNING: Changes to server options in %s take effect only after a restart.", r.path)
+	}
+
+	// The credentials and the audit log are replaced in place, as the HTTP
+	// handlers and the tools hold on to them. Turning them on or off needs a
+	// restart, until then the current ones stay in use. The credentials are
+	// loaded first, so that a configuration with unreadable files is
+	// rejected before anything has changed.
+	var credentials *Authenticator
+	if (r.authenticator != nil) != (cfg.Specification.Auth != nil) && r.opts.Transport != "stdio" {
+		log.Printf("WARNING: Enabling or disabling auth in %s takes effect only after a restart.", r.path)
+	} else if r.authenticator != nil {
+		if credentials, err = NewAuthenticator(cfg.Specification.Auth); err != nil {
+			return err
+		}
+	}
+	if (r.auditLog != nil) != (cfg.Specification.Audit != nil) {
+		log.Printf("WARNING: Enabling or disabling audit in %s takes effect only after a restart.", r.path)
+	} else if r.auditLog != nil {
+		if err := r.auditLog.reload(cfg.Specification.Audit); err != nil {
+			return err
+		}
+	}
+	if credentials != nil {
+		r.authenticator.replace(credentials)
+	}
+
+	r.watchFiles(cfg)
+
+	oldTools, oldResources := r.registry.Tools(), r.registry.Resources()
+	r.registry.set(cfg)
+	newTools, newResources := r.registry.Tools(), r.registry.Resources()
+	resourceCache.Retain(newResources)
+	r.monitor.Update(newResources)
+
+	var removedTools []string
+	for name := range oldTools {
+		if _, ok := newTools[name]; !ok {
+			removedTools = append(removedTools, name)
+		}
+	}
+	var changedTools []server.ServerTool
+	var changedToolNames []string
+	for name, item := range newTools {
+		if old, ok := oldTools[name]; !ok || !reflect.DeepEqual(old, item) {
+			changedTools = append(changedTools, configTool(item, r.taskStore, r.subscriptions, r.auditLog, r.tmpDir, r.verbose))
+			changedToolNames = append(changedToolNames, name)
+		}
+	}
+
+	var removedResources []string
+	for uri := range oldResources {
+		if _, ok := newResources[uri]; !ok {
+			removedResources = append(removedResources, uri)
+		}
+	}
+	var changedResources []server.ServerResource
+	var changedResourceURIs []string
+	for uri, item := range newResources {
+		if old, ok := oldResources[uri]; !ok || !reflect.DeepEqual(old, item) {
+			if item.URITemplate == "" {
+				changedResources = append(changedResources, configResource(item, r.auditLog, r.tmpDir, r.verbose))
+			}
+			changedResourceURIs = append(changedResourceURIs, uri)
+		}
+	}
+
+	// Resources with a URI template and the files of directories are served
+	// by resource templates, which cannot be replaced one by one.
+	templatesChanged := false
+	for _, uri := range append(removedResources, changedResourceURIs...) {
+		if oldResources[uri].hasTemplate() || newResources[uri].hasTemplate() {
+			templatesChanged = true
+		}
+	}
+
+	// Each of these calls notifies the clients that the list has changed.
+	if len(removedTools) > 0 {
+		r.server.DeleteTools(removedTools...)
+	}
+	if len(changedTools) > 0 {
+		r.server.AddTools(changedTools...)
+	}
+	if len(removedResources) > 0 {
+		r.server.DeleteResources(removedResources...)
+	}
+	if len(changedResources) > 0 {
+		r.server.AddResources(changedResources...)
+	}
+	if templatesChanged {
+		templates := []server.ServerResourceTemplate{outputTemplate()}
+		for _, item := range newResources {
+			if template, ok := configTemplate(item, r.auditLog, r.tmpDir, r.verbose); ok {
+				templates = append(templates, template)
+			}
+		}
+		r.server.SetResourceTemplates(templates...)
+	}
+
+	sort.Strings(removedTools)
+	sort.Strings(changedToolNames)
+	sort.Strings(removedResources)
+	sort.Strings(changedResourceURIs)
+	log.Printf("Configuration reloaded from %s: tools added or updated: %v, removed: %v; resources added or updated: %v, removed: %v",
+		r.path, changedToolNames, removedTools, changedResourceURIs, removedResources)
+	return nil
+}
+
+// reloadAndLog reloads the configuration and logs the outcome.
+func (r *configReloader) reloadAndLog() {
+	if err := r.reload(); err != nil {
+		log.Printf("ERROR: Failed to reload configuration, keeping the old one: %v", err)
+	}
+}
+
+// watch reloads the configuration whenever one of its files changes or a
+// file is added to or removed from the drop-in directory. Directories are
+// watched rather than the files themselves, so that files replaced by editors
+// or configuration management (write to a temporary file, then rename) are
+// picked up as well.
+func (r *configReloader) watch(cfg *Config) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	r.mu.Lock()
+	r.watcher = watcher
+	r.watchFiles(cfg)
+	r.mu.Unlock()
+
+	go func() {
+		defer watcher.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod || !r.relevant(event.Name) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDelay, r.reloadAndLog)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("ERROR: Watching %s failed: %v", r.path, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// watchFiles starts watching the directories of the files of cfg. The caller
+// must hold r.mu.
+func (r *configReloader) watchFiles(cfg *Config) {
+	if r.watcher == nil {
+		return
+	}
+	r.files = make(map[string]bool)
+	dirs := map[string]bool{dropInDir(r.path): true}
+	for _, file := range cfg.Files {
+		r.files[filepath.Clean(file)] = true
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range dirs {
+		// The drop-in directory does not need to exist.
+		r.watcher.Add(dir)
+	}
+}
+
+// relevant reports whether a change of the named file affects the
+// configuration.
+func (r *configReloader) relevant(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name = filepath.Clean(name)
+	if r.files[name] {
+		return true
+	}
+	return filepath.Dir(name) == filepath.Clean(dropInDir(r.path)) && filepath.Ext(name) == ".yaml"
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestConfigReloader(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "simple-mcp.yaml")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig(`
+apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Hello
+      description: Says hello
+      command: echo hello
+    - name: Obsolete
+      description: Will be removed
+      command: "true"
+  resources:
+    - uri: test://static
+      content: old
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	taskStore := NewTaskStore(10)
+	registry := NewRegistry(cfg, taskStore)
+	mcpServer := server.NewMCPServer("test", "v1", server.WithToolCapabilities(true), server.WithResourceCapabilities(true, true))
+	subscriptions := NewSubscriptions()
+	registerConfigTools(mcpServer, cfg, taskStore, subscriptions, nil, dir, false)
+	registerResources(mcpServer, cfg, nil, dir, false)
+
+	reloader := &configReloader{
+		path:          path,
+		server:        mcpServer,
+		registry:      registry,
+		resolve:       func(cfg *Config) Options { return Options{} },
+		taskStore:     taskStore,
+		subscriptions: subscriptions,
+		tmpDir:        dir,
+	}
+
+	callTool := func(name string) string {
+		t.Helper()
+		tool := mcpServer.GetTool(name)
+		if tool == nil {
+			t.Fatalf("tool %s is not registered", name)
+		}
+		request := mcp.CallToolRequest{}
+		request.Params.Name = name
+		result, err := tool.Handler(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Content[0].(mcp.TextContent).Text
+	}
+	readResource := func(uri string) string {
+		t.Helper()
+		message, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0", "id": 1, "method": "resources/read",
+			"params": map[string]any{"uri": uri},
+		})
+		response, _ := json.Marshal(mcpServer.HandleMessage(context.Background(), message))
+		return string(response)
+	}
+
+	writeConfig(`
+apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Hello
+      description: Says hello
+      command: echo hello again
+    - name: New
+      description: Was added
+      command: echo new
+  resources:
+    - uri: test://static
+      content: new
+`)
+	if err := reloader.reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mcpServer.GetTool("Obsolete") != nil {
+		t.Error("expected removed tool to be unregistered")
+	}
+	if out := callTool("Hello"); out != "hello again\n" {
+		t.Errorf("expected updated tool, got %q", out)
+	}
+	if out := callTool("New"); out != "new\n" {
+		t.Errorf("expected added tool, got %q", out)
+	}
+	if out := readResource("test://static"); !strings.Contains(out, `"text":"new"`) {
+		t.Errorf("expected updated resource, got %s", out)
+	}
+	if registry.Resources()["test://static"].Content != "new" {
+		t.Error("expected registry to hold the new resource")
+	}
+
+	// A broken file is rejected and the running configuration is kept.
+	writeConfig(`
+apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Hello
+      command: [broken
+`)
+	err = reloader.reload()
+	if err == nil || !strings.Contains(err.Error(), "line") {
+		t.Errorf("expected error with the line number, got %v", err)
+	}
+	if out := callTool("Hello"); out != "hello again\n" {
+		t.Errorf("expected previous tool to stay live, got %q", out)
+	}
+	if _, ok := registry.Tools()["New"]; !ok {
+		t.Error("expected registry to keep the previous configuration")
+	}
+}
+
+func TestConfigReloader_AuthAndAudit(t *testing.T) {
+	dir := t.TempDir()
+	tokens := writeTestFile(t, dir, "tokens", "ci-bot:old-token\n")
+	writeConfig := func(auditPath string) string {
+		t.Helper()
+		return writeTestFile(t, dir, "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  auth:
+    bearerTokensFile: `+tokens+`
+  audit:
+    path: `+auditPath+`
+`)
+	}
+	path := writeConfig(filepath.Join(dir, "audit.jsonl"))
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	authenticator, err := NewAuthenticator(cfg.Specification.Auth)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	auditLog, err := NewAuditLog(cfg.Specification.Audit)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer auditLog.Close()
+
+	reloader := &configReloader{
+		path:          path,
+		server:        server.NewMCPServer("test", "v1"),
+		registry:      NewRegistry(cfg, nil),
+		resolve:       func(cfg *Config) Options { return Options{} },
+		authenticator: authenticator,
+		auditLog:      auditLog,
+	}
+	authenticate := func(token string) bool {
+		t.Helper()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		_, err := authenticator.Authenticate(r)
+		return err == nil
+	}
+
+	// A revoked token is rejected after a reload, and records go to the new
+	// audit log.
+	writeTestFile(t, dir, "tokens", "ci-bot:new-token\n")
+	writeConfig(filepath.Join(dir, "audit-new.jsonl"))
+	if err := reloader.reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if authenticate("old-token") || !authenticate("new-token") {
+		t.Error("expected only the new token to be accepted")
+	}
+	auditLog.Record(context.Background(), AuditRecord{Kind: "tool", Tool: "Hello"})
+	if records := readAuditRecords(t, filepath.Join(dir, "audit-new.jsonl")); len(records) != 1 {
+		t.Errorf("expected a record in the new audit log, got %v", records)
+	}
+
+	// Unreadable credential files reject the configuration.
+	os.Remove(tokens)
+	if err := reloader.reload(); err == nil {
+		t.Error("expected an error")
+	}
+	if !authenticate("new-token") {
+		t.Error("expected the previous credentials to stay in use")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides structured tool results. Tools with structuredOutput
+// return stdout and stderr as separate content blocks and report them with the
+// exit code and duration of the command as structured content, so clients do
+// not have to parse them out of the text.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// commandResultSchema is the output schema of tools with structuredOutput.
+var commandResultSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "exitCode": {
+      "type": "integer",
+      "description": "Exit code of the command, -1 if it did not exit by itself"
+    },
+    "durationMs": {
+      "type": "integer",
+      "description": "Run time of the command in milliseconds"
+    },
+    "stdout": {
+      "type": "string",
+      "description": "Standard output of the command"
+    },
+    "stderr": {
+      "type": "string",
+      "description": "Standard error output of the command"
+    }
+  },
+  "required": ["exitCode", "durationMs", "stdout", "stderr"]
+}`)
+
+// commandResult is the structured content of the result of a tool.
+type commandResult struct {
+	ExitCode   int    `json:"exitCode"`
+	DurationMs int64  `json:"durationMs"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+}
+
+// structuredToolResult returns the result of a tool with structuredOutput. The
+// first content block is stdout, the second is stderr, and a failure of the
+// command adds a third with the error. As the blocks are not labeled, stdout
+// and stderr are also part of the structured content.
+func structuredToolResult(output commandOutput, exitCode int, duration time.Duration, err error) *mcp.CallToolResult {
+	result := &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(output.Stdout),
+			mcp.NewTextContent(output.Stderr),
+		},
+		StructuredContent: commandResult{
+			ExitCode:   exitCode,
+			DurationMs: duration.Milliseconds(),
+			Stdout:     output.Stdout,
+			Stderr:     output.Stderr,
+		},
+	}
+	if err != nil {
+		result.IsError = true
+		result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf("Command failed: %v", err)))
+	}
+	return result
+}
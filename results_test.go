@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestExecuteCommandSplit(t *testing.T) {
+	item := ContextItem{Command: "echo out; echo err >&2; exit 3"}
+	output, exitCode, _, err := executeCommandSplit(context.Background(), item, nil, "")
+	if err == nil || exitCode != 3 {
+		t.Errorf("expected exit code 3 to fail, got %d, %v", exitCode, err)
+	}
+	if output.Stdout != "out\n" || output.Stderr != "err\n" {
+		t.Errorf("expected stdout and stderr apart, got %+v", output)
+	}
+
+	// grep finding nothing is not a failure.
+	item = ContextItem{Command: "grep nothing /dev/null", SuccessExitCodes: []int{0, 1}}
+	if _, exitCode, _, err := executeCommand(context.Background(), item, nil, ""); err != nil || exitCode != 1 {
+		t.Errorf("expected exit code 1 to succeed, got %d, %v", exitCode, err)
+	}
+	item.Command = "exit 2"
+	if _, _, _, err := executeCommand(context.Background(), item, nil, ""); err == nil {
+		t.Error("expected exit code 2 to fail")
+	}
+}
+
+func TestStructuredToolResult(t *testing.T) {
+	item := ContextItem{
+		Name:             "Grep",
+		Command:          "echo match; echo warning >&2; exit {{.code}}",
+		Parameters:       []ToolParameter{{Name: "code", Type: "integer"}},
+		SuccessExitCodes: []int{0, 1},
+		StructuredOutput: true,
+	}
+	tool := configTool(item, nil, nil, nil, "", false)
+	if string(tool.Tool.RawOutputSchema) != string(commandResultSchema) {
+		t.Errorf("expected the output schema of command results, got %s", tool.Tool.RawOutputSchema)
+	}
+
+	call := func(code int) *mcp.CallToolResult {
+		t.Helper()
+		var request mcp.CallToolRequest
+		request.Params.Arguments = map[string]any{"code": code}
+		result, err := tool.Handler(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return result
+	}
+
+	result := call(1)
+	if result.IsError || len(result.Content) != 2 {
+		t.Fatalf("expected a successful result with two blocks, got %+v", result)
+	}
+	if stdout := result.Content[0].(mcp.TextContent).Text; stdout != "match\n" {
+		t.Errorf("expected stdout in the first block, got %q", stdout)
+	}
+	if stderr := result.Content[1].(mcp.TextContent).Text; stderr != "warning\n" {
+		t.Errorf("expected stderr in the second block, got %q", stderr)
+	}
+	data, err := json.Marshal(result.StructuredContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var structured map[string]any
+	json.Unmarshal(data, &structured)
+	if structured["exitCode"] != float64(1) || structured["durationMs"] == nil {
+		t.Errorf("expected the exit code and duration, got %s", data)
+	}
+	if structured["stdout"] != "match\n" || structured["stderr"] != "warning\n" {
+		t.Errorf("expected stdout and stderr, got %s", data)
+	}
+
+	result = call(2)
+	if !result.IsError || len(result.Content) != 3 || result.StructuredContent.(commandResult).ExitCode != 2 {
+		t.Fatalf("expected a failed result with exit code 2, got %+v", result)
+	}
+	if text := result.Content[2].(mcp.TextContent).Text; !strings.Contains(text, "exit status 2") {
+		t.Errorf("expected the error in the third block, got %q", text)
+	}
+}
+
+func TestLoadConfig_SuccessExitCodes(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Grep
+      command: "grep -r pattern /etc"
+      successExitCodes: [0, 1, 256]
+`)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "tool Grep: invalid exit code 256") {
+		t.Errorf("expected the invalid exit code to be rejected, got %v", err)
+	}
+	if problems := ValidateConfig(path); len(problems) != 1 || problems[0].Line != 7 {
+		t.Errorf("expected one problem on line 7, got %v", problems)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the isolation of commands. Commands with limits or a
+// sandbox are started through the server binary itself, which sets up the
+// mounts and rlimits, switches to the user of the command and then executes
+// it. A sandboxed command runs in its own mount, network and IPC namespaces,
+// with no network, a private /tmp and a read-only root file system except for
+// the scratch directory.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// execHelperArg is the first argument of the server binary when it runs as
+// the helper starting an isolated command.
+const execHelperArg = "__simple-mcp-exec"
+
+// execSpec tells the helper how to isolate the command.
+type execSpec struct {
+	Rlimits    []rlimit            `json:"rlimits,omitempty"`
+	Sandbox    bool                `json:"sandbox,omitempty"`
+	ScratchDir string              `json:"scratchDir,omitempty"` // Stays writable in the sandbox
+	Dir        string              `json:"dir"`
+	Credential *syscall.Credential `json:"credential,omitempty"`
+}
+
+// The helper runs before anything else in the process, which also works for
+// test binaries.
+func init() {
+	if len(os.Args) > 2 && os.Args[1] == execHelperArg {
+		runExecHelper(os.Args[2], os.Args[3:])
+	}
+}
+
+// isolateCommand changes cmd to be started through the helper, which applies
+// the limits and the sandbox of item. workDir is the scratch directory, if
+// any. The returned function must be called after the command finished.
+func isolateCommand(cmd *exec.Cmd, item ContextItem, workDir string) (func(), error) {
+	spec := execSpec{
+		Sandbox:    item.Sandbox,
+		ScratchDir: workDir,
+		Dir:        cmd.Dir,
+		Credential: cmd.SysProcAttr.Credential,
+	}
+	// The helper needs its privileges to set up the sandbox and switches
+	// to the user of the command itself.
+	cmd.SysProcAttr.Credential = nil
+	if item.Sandbox {
+		cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC
+	}
+
+	cleanup := func() {}
+	useCgroup := false
+	if limits := item.Limits; limits != nil && (limits.MemoryMB > 0 || limits.Processes > 0) && commandCgroups() != "" {
+		cg, err := newCommandCgroup(commandCgroups(), limits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cgroup: %w", err)
+		}
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(cg.dir.Fd())
+		cleanup = cg.remove
+		useCgroup = true
+	}
+	spec.Rlimits = item.Limits.rlimits(useCgroup)
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	// The helper looks the program up in the PATH of the command.
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = append([]string{"simple-mcp", execHelperArg, string(data)}, cmd.Args...)
+	cmd.Err = nil
+	return cleanup, nil
+}
+
+// runExecHelper isolates the process as described by specJSON and executes
+// argv. It does not return.
+func runExecHelper(specJSON string, argv []string) {
+	fail := func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "simple-mcp: "+format+"\n", args...)
+		os.Exit(126)
+	}
+
+	var spec execSpec
+	if err := json.Unmarshal([]byte(specJSON), &spec); err != nil || len(argv) == 0 {
+		fail("invalid invocation of %s", execHelperArg)
+	}
+
+	if spec.Sandbox {
+		if err := setupSandbox(spec.ScratchDir); err != nil {
+			fail("failed to set up the sandbox: %v", err)
+		}
+		// The working directory may have been hidden by a new mount.
+		if err := os.Chdir(spec.Dir); err != nil {
+			fail("%v", err)
+		}
+	}
+
+	for _, limit := range spec.Rlimits {
+		var current syscall.Rlimit
+		if err := syscall.Getrlimit(limit.Resource, &current); err != nil {
+			fail("failed to get rlimit %d: %v", limit.Resource, err)
+		}
+		// Raising the hard limit needs privileges, so it caps the limit.
+		value := min(limit.Value, current.Max)
+		if err := syscall.Setrlimit(limit.Resource, &syscall.Rlimit{Cur: value, Max: value}); err != nil {
+			fail("failed to set rlimit %d: %v", limit.Resource, err)
+		}
+	}
+
+	if cred := spec.Credential; cred != nil {
+		groups := make([]int, len(cred.Groups))
+		for i, gid := range cred.Groups {
+			groups[i] = int(gid)
+		}
+		if err := syscall.Setgroups(groups); err != nil {
+			fail("failed to set groups: %v", err)
+		}
+		if err := syscall.Setgid(int(cred.Gid)); err != nil {
+			fail("failed to set group: %v", err)
+		}
+		if err := syscall.Setuid(int(cred.Uid)); err != nil {
+			fail("failed to set user: %v", err)
+		}
+	}
+
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		fail("%v", err)
+	}
+	err = syscall.Exec(path, argv, os.Environ())
+	fail("failed to execute %s: %v", argv[0], err)
+}
+
+// setupSandbox makes all mounts read-only, mounts a private /tmp and keeps
+// the scratch directory writable. The process must be in a mount namespace
+// of its own.
+func setupSandbox(scratchDir string) error {
+	// Keep the changes from propagating to the mounts of the host.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+
+	// The scratch directory may be below /tmp, so it is opened before the
+	// private /tmp hides it.
+	var scratch *os.File
+	if scratchDir != "" {
+		var err error
+		if scratch, err = os.Open(scratchDir); err != nil {
+			return err
+		}
+		defer scratch.Close()
+	}
+
+	mounts, err := mountPoints()
+	if err != nil {
+		return err
+	}
+	if err := remountReadOnly(mounts, func(mount mountPoint) error {
+		return syscall.Mount("", mount.path, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|mount.flags, "")
+	}); err != nil {
+		return err
+	}
+
+	if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mounting /tmp: %w", err)
+	}
+
+	if scratch != nil {
+		if err := os.MkdirAll(scratchDir, 0700); err != nil {
+			return err
+		}
+		source := fmt.Sprintf("/proc/self/fd/%d", scratch.Fd())
+		if err := syscall.Mount(source, scratchDir, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("mounting the scratch directory: %w", err)
+		}
+		// The bind mount is read-only like its source until remounted.
+		if err := syscall.Mount("", scratchDir, "", syscall.MS_BIND|syscall.MS_REMOUNT, ""); err != nil {
+			return fmt.Errorf("mounting the scratch directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// remountReadOnly makes the mounts read-only with remount. Mounts hidden by
+// later mounts on the same path or on a parent directory cannot be changed,
+// which is fine as they cannot be reached either. Failing to change any other
+// mount is an error.
+func remountReadOnly(mounts []mountPoint, remount func(mountPoint) error) error {
+	for i, mount := range mounts {
+		err := remount(mount)
+		if err == nil || isHiddenMount(mounts, i) {
+			continue
+		}
+		return fmt.Errorf("making %s read-only: %w", mount.path, err)
+	}
+	return nil
+}
+
+// isHiddenMount reports whether mounts[i] is covered by a later mount.
+func isHiddenMount(mounts []mountPoint, i int) bool {
+	for _, later := range mounts[i+1:] {
+		if later.path == "/" || later.path == mounts[i].path || strings.HasPrefix(mounts[i].path, later.path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type mountPoint struct {
+	path  string
+	flags uintptr // Flags to preserve on remount
+}
+
+// mountPoints returns the mounts of the process from /proc/self/mountinfo.
+func mountPoints() ([]mountPoint, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Remounting fails if a locked flag such as nosuid is dropped.
+	options := map[string]uintptr{
+		"nosuid":     syscall.MS_NOSUID,
+		"nodev":      syscall.MS_NODEV,
+		"noexec":     syscall.MS_NOEXEC,
+		"noatime":    syscall.MS_NOATIME,
+		"nodiratime": syscall.MS_NODIRATIME,
+		"relatime":   syscall.MS_RELATIME,
+	}
+	var mounts []mountPoint
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ID, parent ID, major:minor, root, mount point, mount options, ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		mount := mountPoint{path: unescapeMountPath(fields[4])}
+		for _, option := range strings.Split(fields[5], ",") {
+			mount.flags |= options[option]
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes of spaces and other special
+// characters in mountinfo.
+func unescapeMountPath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			var c byte
+			if _, err := fmt.Sscanf(path[i+1:i+4], "%03o", &c); err == nil {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestExecuteCommand_Limits(t *testing.T) {
+	item := ContextItem{
+		Command: "grep -E 'Max (cpu time|open files|file size)' /proc/self/limits",
+		Limits:  &ResourceLimits{CPUSeconds: 5, OpenFiles: 64, FileSizeMB: 1},
+	}
+	output, _, _, err := executeCommand(context.Background(), item, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v, output: %s", err, output)
+	}
+	for _, want := range []string{
+		"Max cpu time              5                    5",
+		"Max file size             1048576              1048576",
+		"Max open files            64                   64",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in the limits, got:\n%s", want, output)
+		}
+	}
+
+	// Writing more than allowed fails.
+	item = ContextItem{
+		Command: "head -c 2000000 /dev/zero > big",
+		Limits:  &ResourceLimits{FileSizeMB: 1},
+	}
+	if _, _, _, err := executeCommand(context.Background(), item, nil, t.TempDir()); err == nil {
+		t.Error("expected the file size limit to stop the command")
+	}
+}
+
+func TestExecuteCommand_Sandbox(t *testing.T) {
+	if !hasCapabilities(capSysAdmin) {
+		t.Skip("the sandbox requires root")
+	}
+	scratch := t.TempDir()
+	hostFile := filepath.Join(t.TempDir(), "host")
+	if err := os.WriteFile(hostFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	item := ContextItem{
+		Command: `touch /etc/simple-mcp-sandbox-test 2>/dev/null || echo read-only
+test -e ` + hostFile + ` || echo private-tmp
+touch new && echo scratch-writable
+touch /tmp/new && echo tmp-writable
+grep -c : /proc/net/dev`,
+		Sandbox: true,
+	}
+	output, _, _, err := executeCommand(context.Background(), item, nil, scratch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v, output: %s", err, output)
+	}
+	want := "read-only\nprivate-tmp\nscratch-writable\ntmp-writable\n1\n"
+	if output != want {
+		t.Errorf("expected %q, got %q", want, output)
+	}
+	if _, err := os.Stat(filepath.Join(scratch, "new")); err != nil {
+		t.Errorf("expected the file in the scratch directory to persist: %v", err)
+	}
+}
+
+func TestRemountReadOnly(t *testing.T) {
+	mounts := []mountPoint{{path: "/"}, {path: "/mnt/a"}, {path: "/mnt"}, {path: "/proc"}}
+	failing := func(paths ...string) func(mountPoint) error {
+		return func(mount mountPoint) error {
+			for _, path := range paths {
+				if mount.path == path {
+					return syscall.EPERM
+				}
+			}
+			return nil
+		}
+	}
+
+	if err := remountReadOnly(mounts, failing("/mnt/a")); err != nil {
+		t.Errorf("expected a hidden mount to be skipped, got %v", err)
+	}
+	for _, path := range []string{"/", "/mnt", "/proc"} {
+		if err := remountReadOnly(mounts, failing(path)); err == nil || !strings.Contains(err.Error(), "making "+path+" read-only") {
+			t.Errorf("expected failing to remount %s to be an error, got %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the JSON Schema of the configuration file, which is
+// generated from the Go types, and the strict decoding that rejects keys the
+// types do not define, such as a misspelled `timeoutSecond`.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// configSchema returns the JSON Schema of simple-mcp.yaml.
+func configSchema() ([]byte, error) {
+	reflector := &jsonschema.Reflector{
+		FieldNameTag:               "yaml",
+		RequiredFromJSONSchemaTags: true,
+	}
+	schema := reflector.Reflect(&Config{})
+	schema.Title = "simple-mcp configuration"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// JSONSchemaExtend allows a parameter to be given as a bare name, like
+// UnmarshalYAML does, and lists the supported parameter types.
+func (ToolParameter) JSONSchemaExtend(schema *jsonschema.Schema) {
+	if paramType, ok := schema.Properties.Get("type"); ok {
+		paramType.Enum = []any{ParamTypeString, ParamTypeInteger, ParamTypeNumber, ParamTypeBoolean, ParamTypeEnum, ParamTypeArray}
+	}
+	object := *schema
+	*schema = jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{{Type: "string"}, &object},
+	}
+}
+
+// JSONSchemaExtend requires a resource to have either a uri or a uriTemplate.
+func (ResourceItem) JSONSchemaExtend(schema *jsonschema.Schema) {
+	schema.OneOf = []*jsonschema.Schema{
+		{Required: []string{"uri"}},
+		{Required: []string{"uriTemplate"}},
+	}
+}
+
+// unknownFields returns an error message for every key of node that does not
+// match a field of t, looking into nested mappings and sequences. The
+// messages have the same form as those of the strict yaml.v3 decoder. That
+// decoder is not used, as its strictness does not carry over into types with
+// their own UnmarshalYAML, such as ToolParameter.
+func unknownFields(node *yaml.Node, t reflect.Type) []string {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var errs []string
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				errs = append(errs, unknownFields(value, t)...)
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("line %d: field %s not found in type %s", key.Line, key.Value, t))
+				continue
+			}
+			errs = append(errs, unknownFields(value, field.Type)...)
+		}
+	case reflect.Slice:
+		if node.Kind == yaml.SequenceNode {
+			for _, item := range node.Content {
+				errs = append(errs, unknownFields(item, t.Elem())...)
+			}
+		}
+	case reflect.Map:
+		if node.Kind == yaml.MappingNode {
+			for i := 1; i < len(node.Content); i += 2 {
+				errs = append(errs, unknownFields(node.Content[i], t.Elem())...)
+			}
+		}
+	}
+	return errs
+}
+
+// yamlFields returns the fields of a struct type by their YAML keys.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
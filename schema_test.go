@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigSchema(t *testing.T) {
+	schema, err := configSchema()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	schemaLoader := gojsonschema.NewBytesLoader(schema)
+
+	validate := func(content []byte) *gojsonschema.Result {
+		t.Helper()
+		var doc interface{}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			t.Fatal(err)
+		}
+		result, err := gojsonschema.Validate(schemaLoader, gojsonschema.NewGoLoader(convertToJSONCompatible(doc)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	t.Run("Example configurations", func(t *testing.T) {
+		for _, file := range []string{"simple-mcp.yaml", "unified-core.yaml"} {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := validate(content); !result.Valid() {
+				t.Errorf("%s does not match the schema: %v", file, result.Errors())
+			}
+		}
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		result := validate([]byte(`apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Slow
+      command: sleep 10
+      timeoutSecond: 5
+`))
+		if result.Valid() {
+			t.Error("expected the misspelled field to be rejected")
+		}
+	})
+}
+
+func TestLoadConfig_UnknownFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "Tool",
+			content: `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Slow
+      command: sleep 10
+      timeoutSecond: 5
+`,
+			want: "line 7: field timeoutSecond not found in type main.ContextItem",
+		},
+		{
+			name: "Parameter",
+			content: `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Ping
+      command: "ping -c 1 {{.host}}"
+      parameters:
+        - name: host
+          patern: "^[a-z.]+$"
+`,
+			want: "line 9: field patern not found in type main.ToolParameter",
+		},
+		{
+			name: "Spec",
+			content: `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  listenAdress: localhost:9090
+`,
+			want: "line 4: field listenAdress not found in type main.Spec",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", tt.content)
+			_, err := LoadConfig(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+			if problems := ValidateConfig(path); len(problems) != 1 || !strings.Contains(tt.want, problems[0].Message) {
+				t.Errorf("expected one problem for %q, got %v", tt.want, problems)
+			}
+		})
+	}
+}
@@ -22,12 +22,17 @@ import (
 )
 
 // registerScratchTools registers the file and directory manipulation tools.
-func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]ResourceItem, tmpDir string, verbose bool) {
+func registerScratchTools(mcpServer *server.MCPServer, registry *Registry, auditLog *AuditLog, tmpDir string, verbose bool) {
+	// Every scratch space operation is recorded in the audit log.
+	addTool := func(tool mcp.Tool, handler server.ToolHandlerFunc) {
+		mcpServer.AddTool(tool, auditLog.wrapScratch(tool.Name, handler))
+	}
+
 	createFileTool := mcp.NewTool("CreateFile",
 		mcp.WithDescription("Creates a new file in the scratch space."),
 		mcp.WithString("path", mcp.Required(), mcp.Description("The path to the file within the scratch space.")),
 		mcp.WithString("content", mcp.Required(), mcp.Description("The content of the file. Do not forget to include a newline character on the last line of a text file.")))
-	mcpServer.AddTool(createFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(createFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		path, _ := request.RequireString("path")
 		content, _ := request.RequireString("content")
 		if verbose {
@@ -40,7 +45,7 @@ func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]Re
 	readFileTool := mcp.NewTool("ReadFile",
 		mcp.WithDescription("Reads the content of a file in the scratch space."),
 		mcp.WithString("path", mcp.Required(), mcp.Description("The path to the file within the scratch space.")))
-	mcpServer.AddTool(readFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(readFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		path, _ := request.RequireString("path")
 		if verbose {
 			log.Printf("Handling ReadFile request for path: %s", path)
@@ -52,7 +57,7 @@ func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]Re
 	deleteFileTool := mcp.NewTool("DeleteFile",
 		mcp.WithDescription("Deletes a file in the scratch space."),
 		mcp.WithString("path", mcp.Required(), mcp.Description("The path to the file within the scratch space.")))
-	mcpServer.AddTool(deleteFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(deleteFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		path, _ := request.RequireString("path")
 		if verbose {
 			log.Printf("Handling DeleteFile request for path: %s", path)
@@ -67,7 +72,7 @@ func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]Re
 		mcp.WithString("pattern", mcp.Required(), mcp.Description("The regular expression pattern to search for.")),
 		mcp.WithString("replacement", mcp.Required(), mcp.Description("The replacement string. Supports capture groups (e.g., $1).")),
 		mcp.WithBoolean("replaceAll", mcp.Description("If true, replace all occurrences. If false (default), replace only the first occurrence.")))
-	mcpServer.AddTool(replaceInFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(replaceInFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		path, _ := request.RequireString("path")
 		pattern, _ := request.RequireString("pattern")
 		replacement, _ := request.RequireString("replacement")
@@ -82,7 +87,7 @@ func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]Re
 	listDirectoryTool := mcp.NewTool("ListDirectory",
 		mcp.WithDescription("Lists the contents of a directory in the scratch space."),
 		mcp.WithString("path", mcp.Required(), mcp.Description("The path to the directory within the scratch space. Absolute paths are not allowed.")))
-	mcpServer.AddTool(listDirectoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(listDirectoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		path, _ := request.RequireString("path")
 		if verbose {
 			log.Printf("Handling ListDirectory request for path: %s", path)
@@ -94,7 +99,7 @@ func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]Re
 	createDirectoryTool := mcp.NewTool("CreateDirectory",
 		mcp.WithDescription("Creates a new directory in the scratch space."),
 		mcp.WithString("path", mcp.Required(), mcp.Description("The path to the directory within the scratch space.")))
-	mcpServer.AddTool(createDirectoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(createDirectoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		path, _ := request.RequireString("path")
 		if verbose {
 			log.Printf("Handling CreateDirectory request for path: %s", path)
@@ -106,7 +111,7 @@ func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]Re
 	removeDirectoryTool := mcp.NewTool("RemoveDirectory",
 		mcp.WithDescription("Removes an empty directory in the scratch space."),
 		mcp.WithString("path", mcp.Required(), mcp.Description("The path to the directory within the scratch space.")))
-	mcpServer.AddTool(removeDirectoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(removeDirectoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		path, _ := request.RequireString("path")
 		if verbose {
 			log.Printf("Handling RemoveDirectory request for path: %s", path)
@@ -119,13 +124,13 @@ func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]Re
 		mcp.WithDescription("Copies the content of a resource to a file in the scratch space."),
 		mcp.WithString("resourceURI", mcp.Required(), mcp.Description("The URI of the resource to copy.")),
 		mcp.WithString("path", mcp.Required(), mcp.Description("The path to the destination file within the scratch space.")))
-	mcpServer.AddTool(copyResourceToFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(copyResourceToFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		resourceURI, _ := request.RequireString("resourceURI")
 		path, _ := request.RequireString("path")
 		if verbose {
 			log.Printf("Handling CopyResourceToFile request for resourceURI: %s, path: %s", resourceURI, path)
 		}
-		return copyResourceToFile(resourceMap, tmpDir, verbose, resourceURI, path)
+		return copyResourceToFile(ctx, registry.visibleResources(ctx), auditLog, tmpDir, verbose, resourceURI, path)
 	})
 	log.Printf("Registered built-in scratch tool: %s", copyResourceToFileTool.Name)
 
@@ -133,24 +138,24 @@ func registerScratchTools(mcpServer *server.MCPServer, resourceMap map[string]Re
 		mcp.WithDescription("Recursively copies all resources whose URIs start with a given prefix into a directory in the scratch space."),
 		mcp.WithString("resourcePrefix", mcp.Required(), mcp.Description("The prefix of the resource URIs to copy.")),
 		mcp.WithString("destinationPath", mcp.Required(), mcp.Description("The destination directory path within the scratch space.")))
-	mcpServer.AddTool(copyResourceTreeTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addTool(copyResourceTreeTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		resourcePrefix, _ := request.RequireString("resourcePrefix")
 		destinationPath, _ := request.RequireString("destinationPath")
 		if verbose {
 			log.Printf("Handling CopyResourceTree request for resourcePrefix: %s, destinationPath: %s", resourcePrefix, destinationPath)
 		}
-		return copyResourceTree(resourceMap, tmpDir, verbose, resourcePrefix, destinationPath)
+		return copyResourceTree(ctx, registry.visibleResources(ctx), auditLog, tmpDir, verbose, resourcePrefix, destinationPath)
 	})
 	log.Printf("Registered built-in scratch tool: %s", copyResourceTreeTool.Name)
 }
 
-func copyResourceToFile(resourceMap map[string]ResourceItem, tmpDir string, verbose bool, resourceURI, path string) (*mcp.CallToolResult, error) {
-	item, ok := resourceMap[resourceURI]
+func copyResourceToFile(ctx context.Context, resourceMap map[string]ResourceItem, auditLog *AuditLog, tmpDir string, verbose bool, resourceURI, path string) (*mcp.CallToolResult, error) {
+	item, ok := lookupResource(resourceMap, resourceURI)
 	if !ok {
 		return mcp.NewToolResultError(fmt.Sprintf("resource not found: %s", resourceURI)), nil
 	}
 
-	content, err := getResourceContent(item, tmpDir, verbose)
+	content, err := getResourceContent(ctx, item, auditLog, tmpDir, verbose)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to get resource content for %s: %v", resourceURI, err)), nil
 	}
@@ -162,7 +167,9 @@ func copyResourceToFile(resourceMap map[string]ResourceItem, tmpDir string, verb
 	return createFile(tmpDir, path, content)
 }
 
-func copyResourceTree(resourceMap map[string]ResourceItem, tmpDir string, verbose bool, resourcePrefix, destinationPath string) (*mcp.CallToolResult, error) {
+func copyResourceTree(ctx context.Context, resourceMap map[string]ResourceItem, auditLog *AuditLog, tmpDir string, verbose bool, resourcePrefix, destinationPath string) (*mcp.CallToolResult, error) {
+	// The files of directories are copied rather than their listing.
+	resourceMap = expandDirectories(resourceMap)
 	var matchedURIs []string
 	for uri := range resourceMap {
 		if uri == resourcePrefix {
@@ -191,7 +198,7 @@ func copyResourceTree(resourceMap map[string]ResourceItem, tmpDir string, verbos
 			targetPath = filepath.Join(destinationPath, relPath)
 		}
 
-		content, err := getResourceContent(item, tmpDir, verbose)
+		content, err := getResourceContent(ctx, item, auditLog, tmpDir, verbose)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("failed to get resource content for %s: %v", uri, err)), nil
 		}
@@ -8,6 +8,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -60,14 +61,14 @@ func TestScratchLogic(t *testing.T) {
 			},
 		}
 
-		res, err := copyResourceToFile(resourceMap, tmpDir, false, "simple-mcp://content", "resource-file.txt")
+		res, err := copyResourceToFile(context.Background(), resourceMap, nil, tmpDir, false, "simple-mcp://content", "resource-file.txt")
 		require.NoError(t, err)
 		assert.Equal(t, "File created successfully.", res.Content[0].(mcp.TextContent).Text)
 		content, err := os.ReadFile(filepath.Join(tmpDir, "resource-file.txt"))
 		assert.NoError(t, err)
 		assert.Equal(t, "resource content", string(content))
 
-		res, err = copyResourceToFile(resourceMap, tmpDir, false, "simple-mcp://command", "command-file.txt")
+		res, err = copyResourceToFile(context.Background(), resourceMap, nil, tmpDir, false, "simple-mcp://command", "command-file.txt")
 		require.NoError(t, err)
 		assert.Equal(t, "File created successfully.", res.Content[0].(mcp.TextContent).Text)
 		content, err = os.ReadFile(filepath.Join(tmpDir, "command-file.txt"))
@@ -84,7 +85,7 @@ func TestScratchLogic(t *testing.T) {
 			},
 		}
 
-		res, err := copyResourceToFile(resourceMap, tmpDir, false, "simple-mcp://combined", "combined-file.txt")
+		res, err := copyResourceToFile(context.Background(), resourceMap, nil, tmpDir, false, "simple-mcp://combined", "combined-file.txt")
 		require.NoError(t, err)
 		assert.Equal(t, "File created successfully.", res.Content[0].(mcp.TextContent).Text)
 		content, err := os.ReadFile(filepath.Join(tmpDir, "combined-file.txt"))
@@ -101,7 +102,7 @@ func TestScratchLogic(t *testing.T) {
 		}
 
 		t.Run("MatchWithSlash", func(t *testing.T) {
-			res, err := copyResourceTree(resourceMap, tmpDir, false, "prefix://a/", "tree-slash")
+			res, err := copyResourceTree(context.Background(), resourceMap, nil, tmpDir, false, "prefix://a/", "tree-slash")
 			require.NoError(t, err)
 			assert.Contains(t, res.Content[0].(mcp.TextContent).Text, "Successfully copied 2 resources")
 
@@ -124,7 +125,7 @@ func TestScratchLogic(t *testing.T) {
 				"prefix://a/file1.txt": {URI: "prefix://a/file1.txt", Content: "content1"},
 				"prefix://a/b/file2.txt": {URI: "prefix://a/b/file2.txt", Content: "content2"},
 			}
-			res, err := copyResourceTree(resourceMapClean, tmpDir, false, "prefix://a", "tree-no-slash")
+			res, err := copyResourceTree(context.Background(), resourceMapClean, nil, tmpDir, false, "prefix://a", "tree-no-slash")
 			require.NoError(t, err)
 			assert.Contains(t, res.Content[0].(mcp.TextContent).Text, "Successfully copied 2 resources")
 
@@ -135,7 +136,7 @@ func TestScratchLogic(t *testing.T) {
 		})
 
 		t.Run("NoMatch", func(t *testing.T) {
-			res, err := copyResourceTree(resourceMap, tmpDir, false, "prefix://nonexistent", "tree-none")
+			res, err := copyResourceTree(context.Background(), resourceMap, nil, tmpDir, false, "prefix://nonexistent", "tree-none")
 			require.NoError(t, err)
 			assert.True(t, res.IsError)
 			assert.Contains(t, res.Content[0].(mcp.TextContent).Text, "no resources found")
@@ -146,7 +147,7 @@ func TestScratchLogic(t *testing.T) {
 			resourceMapPartial := map[string]ResourceItem{
 				"prefix://ab/file.txt": {URI: "prefix://ab/file.txt", Content: "content"},
 			}
-			res, err := copyResourceTree(resourceMapPartial, tmpDir, false, "prefix://a", "tree-partial")
+			res, err := copyResourceTree(context.Background(), resourceMapPartial, nil, tmpDir, false, "prefix://a", "tree-partial")
 			require.NoError(t, err)
 			assert.True(t, res.IsError)
 		})
@@ -158,7 +159,7 @@ func TestScratchLogic(t *testing.T) {
 			resourceMapOverwrite := map[string]ResourceItem{
 				"prefix://a/file1.txt": {URI: "prefix://a/file1.txt", Content: "new content"},
 			}
-			res, err := copyResourceTree(resourceMapOverwrite, tmpDir, false, "prefix://a/", "tree-overwrite")
+			res, err := copyResourceTree(context.Background(), resourceMapOverwrite, nil, tmpDir, false, "prefix://a/", "tree-overwrite")
 			require.NoError(t, err)
 			assert.False(t, res.IsError)
 
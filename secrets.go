@@ -0,0 +1,196 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the interpolation of secrets into the configuration.
+// Values written as ${env:NAME} or ${file:/path} are replaced when the file is
+// loaded, so that credentials need not be stored in it, and are redacted from
+// the command output returned to the LLM, the log and the audit log.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// minSecretLen is the length below which values are not redacted, as
+// replacing every occurrence of e.g. "1" would make the output unreadable.
+const minSecretLen = 4
+
+var interpolationRegex = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// interpolate replaces ${env:NAME} and ${file:/path} references in the scalar
+// values below node. Relative file paths are resolved against dir and a
+// trailing newline of the file is removed. It returns the values that were
+// inserted.
+func interpolate(node *yaml.Node, dir string) ([]string, error) {
+	var values []string
+	var errs []string
+	var walk func(node *yaml.Node, isKey bool)
+	walk = func(node *yaml.Node, isKey bool) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child, false)
+			}
+		case yaml.MappingNode:
+			for i, child := range node.Content {
+				walk(child, i%2 == 0)
+			}
+		case yaml.ScalarNode:
+			if isKey || !strings.Contains(node.Value, "${") {
+				return
+			}
+			node.Value = interpolationRegex.ReplaceAllStringFunc(node.Value, func(ref string) string {
+				match := interpolationRegex.FindStringSubmatch(ref)
+				value, err := lookupSecret(match[1], match[2], dir)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("line %d: %v", node.Line, err))
+					return ref
+				}
+				values = append(values, value)
+				return value
+			})
+			// Let plain scalars be resolved again, so that e.g.
+			// `timeoutSeconds: ${env:TIMEOUT}` is decoded as a number.
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	walk(node, false)
+	if len(errs) > 0 {
+		return nil, &yaml.TypeError{Errors: errs}
+	}
+	return values, nil
+}
+
+// lookupSecret returns the value of an environment variable or the content of
+// a file.
+func lookupSecret(source, name, dir string) (string, error) {
+	if source == "env" {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// redactor replaces secret values with a placeholder. The values are ordered
+// longest first, so that a secret containing another one is replaced whole.
+type redactor []string
+
+// newRedactor returns a redactor for the given values, ignoring values too
+// short to be redacted.
+func newRedactor(values []string) redactor {
+	var r redactor
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if len(value) >= minSecretLen && !seen[value] {
+			seen[value] = true
+			r = append(r, value)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return len(r[i]) > len(r[j]) })
+	return r
+}
+
+// redact returns s with all secret values replaced.
+func (r redactor) redact(s string) string {
+	for _, value := range r {
+		s = strings.ReplaceAll(s, value, redactedValue)
+	}
+	return s
+}
+
+// redactValues redacts the values of a map in place and returns it.
+func (r redactor) redactValues(m map[string]string) map[string]string {
+	for key, value := range m {
+		m[key] = r.redact(value)
+	}
+	return m
+}
+
+// writer returns a writer that redacts the data written to it before passing
+// it on to out. The end of the data is held back until more data arrives or
+// Flush is called, as it may be the beginning of a secret.
+func (r redactor) writer(out io.Writer) *redactWriter {
+	w := &redactWriter{redactor: r, out: out}
+	if len(r) > 0 {
+		w.holdBack = len(r[0]) - 1
+	}
+	return w
+}
+
+type redactWriter struct {
+	redactor redactor
+	out      io.Writer
+	holdBack int
+	pending  []byte
+}
+
+// Write implements io.Writer.
+func (w *redactWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	var redacted bytes.Buffer
+	start := 0
+	for {
+		// Find the first secret in the pending data.
+		pos, length := -1, 0
+		for _, value := range w.redactor {
+			if i := bytes.Index(w.pending[start:], []byte(value)); i >= 0 && (pos < 0 || i < pos) {
+				pos, length = i, len(value)
+			}
+		}
+		// A longer secret may start at the same position and is not
+		// complete yet.
+		if pos < 0 || start+pos+w.holdBack >= len(w.pending) {
+			break
+		}
+		redacted.Write(w.pending[start : start+pos])
+		redacted.WriteString(redactedValue)
+		start += pos + length
+	}
+	// A secret cannot start before safe without having been replaced above.
+	safe := max(start, len(w.pending)-w.holdBack)
+	redacted.Write(w.pending[start:safe])
+	w.pending = append(w.pending[:0], w.pending[safe:]...)
+
+	if redacted.Len() > 0 {
+		if _, err := w.out.Write(redacted.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the data held back.
+func (w *redactWriter) Flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.out, w.redactor.redact(string(w.pending)))
+	w.pending = nil
+	return err
+}
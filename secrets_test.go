@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_Interpolation(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SIMPLE_MCP_TEST_TOKEN", "env-token-123")
+	t.Setenv("SIMPLE_MCP_TEST_TIMEOUT", "5")
+	writeTestFile(t, dir, "api-key", "file-key-456\n")
+	path := writeTestFile(t, dir, "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Call
+      command: 'echo "$TOKEN ${env:SIMPLE_MCP_TEST_TOKEN} ${HOME:+home}"'
+      timeoutSeconds: ${env:SIMPLE_MCP_TEST_TIMEOUT}
+      env:
+        TOKEN: ${file:api-key}
+        LANG: C
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tool := cfg.Specification.Tools[0]
+	if tool.Command != `echo "$TOKEN env-token-123 ${HOME:+home}"` {
+		t.Errorf("unexpected command %q", tool.Command)
+	}
+	if tool.TimeoutSeconds != 5 {
+		t.Errorf("expected timeout from the environment, got %d", tool.TimeoutSeconds)
+	}
+	if tool.Env["TOKEN"] != "file-key-456" {
+		t.Errorf("expected value from the file without the newline, got %q", tool.Env["TOKEN"])
+	}
+
+	output, _, _, err := executeCommand(context.Background(), tool, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "[REDACTED] [REDACTED] home\n" {
+		t.Errorf("expected secrets to be redacted, got %q", output)
+	}
+
+	t.Run("Missing variable", func(t *testing.T) {
+		path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Call
+      command: echo ${env:SIMPLE_MCP_TEST_UNSET}
+`)
+		_, err := LoadConfig(path)
+		if err == nil || !strings.Contains(err.Error(), "line 6: environment variable SIMPLE_MCP_TEST_UNSET is not set") {
+			t.Errorf("expected error with the line number, got %v", err)
+		}
+		problems := ValidateConfig(path)
+		if len(problems) != 1 || problems[0].Line != 6 {
+			t.Errorf("expected one problem on line 6, got %v", problems)
+		}
+	})
+}
+
+func TestRedactWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := newRedactor([]string{"secret", "secret-long", "abc"}).writer(&out)
+	for _, chunk := range []string{"a sec", "ret and a secret-", "long one, se", "cre", "t", " abc sec"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+	want := "a [REDACTED] and a [REDACTED] one, [REDACTED] abc sec"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides a persistent task store. Every change to a task is
+// appended to a JSON-lines journal in the state directory, and the journal is
+// replayed on startup so that task IDs held by the LLM stay valid across
+// server restarts.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalFileName is the name of the journal file within the state directory.
+const journalFileName = "tasks.jsonl"
+
+// interruptedMessage is recorded for tasks that were running when the server
+// stopped.
+const interruptedMessage = "interrupted by restart"
+
+// journalRecord is a single line of the journal. A "put" record holds the
+// complete state of a task, a "delete" record removes it.
+type journalRecord struct {
+	Op   string     `json:"op"`
+	ID   string     `json:"id,omitempty"`
+	Task *AsyncTask `json:"task,omitempty"`
+}
+
+// journalTaskStore keeps tasks in memory like the default store and records
+// every change in the journal.
+type journalTaskStore struct {
+	*memoryTaskStore
+
+	mu      sync.Mutex // Serializes writes to the journal file
+	path    string
+	file    *os.File
+	records int
+}
+
+// NewJournalTaskStore opens (or creates) the task journal in stateDir and
+// restores the tasks recorded in it. Tasks that were still pending or running
+// when the server stopped are marked as failed.
+func NewJournalTaskStore(stateDir string, maxTasks int) (TaskStore, error) {
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory %s: %w", stateDir, err)
+	}
+
+	path := filepath.Join(stateDir, journalFileName)
+	tasks, err := readJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep only the most recent tasks if the limit was lowered.
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].StartTime.After(tasks[j].StartTime)
+	})
+	if len(tasks) > maxTasks {
+		tasks = tasks[:maxTasks]
+	}
+
+	ts := &journalTaskStore{
+		memoryTaskStore: newMemoryTaskStore(maxTasks),
+		path:            path,
+	}
+	now := time.Now()
+	for _, task := range tasks {
+		if !isFinished(task.Status) {
+			task.Status = "failed"
+			task.Message = interruptedMessage
+			task.EndTime = now
+		}
+		task.Output = &TaskOutput{}
+		task.done = make(chan struct{})
+		close(task.done)
+		ts.tasks[strings.ToLower(task.ID)] = task
+	}
+
+	if err := ts.compact(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// readJournal replays the journal at path and returns the resulting tasks.
+// A missing journal is not an error. A truncated last line, as left behind by
+// a crash during a write, is skipped.
+func readJournal(path string) ([]*AsyncTask, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task journal %s: %w", path, err)
+	}
+	defer file.Close()
+
+	tasks := make(map[string]*AsyncTask)
+	reader := bufio.NewReader(file)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record journalRecord
+			if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+				log.Printf("WARNING: Skipping invalid record on line %d of task journal %s: %v", lineNum, path, jsonErr)
+			} else {
+				switch {
+				case record.Op == "put" && record.Task != nil:
+					tasks[strings.ToLower(record.Task.ID)] = record.Task
+				case record.Op == "delete":
+					delete(tasks, strings.ToLower(record.ID))
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task journal %s: %w", path, err)
+		}
+	}
+
+	result := make([]*AsyncTask, 0, len(tasks))
+	for _, task := range tasks {
+		result = append(result, task)
+	}
+	return result, nil
+}
+
+// compact rewrites the journal so that it contains exactly one record per
+// task, and reopens it for appending.
+func (ts *journalTaskStore) compact() error {
+	tmpPath := ts.path + ".tmp"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write task journal %s: %w", tmpPath, err)
+	}
+
+	writer := bufio.NewWriter(tmpFile)
+	encoder := json.NewEncoder(writer)
+	tasks := ts.ListTasks()
+	for _, task := range tasks {
+		snapshot := ts.snapshot(task.ID)
+		if err := encoder.Encode(journalRecord{Op: "put", Task: &snapshot}); err != nil {
+			tmpFile.Close()
+			return fmt.Errorf("failed to write task journal %s: %w", tmpPath, err)
+		}
+	}
+	if err := writer.Flush(); err == nil {
+		err = tmpFile.Sync()
+	}
+	if err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write task journal %s: %w", tmpPath, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write task journal %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, ts.path); err != nil {
+		return fmt.Errorf("failed to replace task journal %s: %w", ts.path, err)
+	}
+
+	file, err := os.OpenFile(ts.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open task journal %s: %w", ts.path, err)
+	}
+	if ts.file != nil {
+		ts.file.Close()
+	}
+	ts.file = file
+	ts.records = len(tasks)
+	return nil
+}
+
+// snapshot returns a copy of a task taken under the store lock, suitable for
+// serializing while the task keeps changing.
+func (ts *journalTaskStore) snapshot(id string) AsyncTask {
+	ts.memoryTaskStore.mu.RLock()
+	defer ts.memoryTaskStore.mu.RUnlock()
+
+	if task, ok := ts.tasks[strings.ToLower(id)]; ok {
+		return *task
+	}
+	return AsyncTask{ID: id}
+}
+
+// append writes a record to the journal, compacting it once it has grown to
+// many times the number of tasks. Journal errors are logged but do not fail
+// the operation, as the in-memory state is still correct.
+func (ts *journalTaskStore) append(record journalRecord) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.appendLocked(record)
+}
+
+func (ts *journalTaskStore) appendLocked(record journalRecord) {
+	data, err := json.Marshal(record)
+	if err == nil {
+		_, err = ts.file.Write(append(data, '\n'))
+	}
+	if err == nil {
+		err = ts.file.Sync()
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to write task journal %s: %v", ts.path, err)
+		return
+	}
+
+	ts.records++
+	if ts.records > 10*ts.maxTasks+100 {
+		if err := ts.compact(); err != nil {
+			log.Printf("ERROR: Failed to compact task journal: %v", err)
+		}
+	}
+}
+
+func (ts *journalTaskStore) Create(id string, toolName string) *AsyncTask {
+	task := ts.memoryTaskStore.Create(id, toolName)
+	ts.recordTask(id)
+	return task
+}
+
+func (ts *journalTaskStore) Delete(id string) {
+	ts.memoryTaskStore.Delete(id)
+	ts.append(journalRecord{Op: "delete", ID: id})
+}
+
+func (ts *journalTaskStore) SetStatus(id string, status string, message string) {
+	ts.memoryTaskStore.SetStatus(id, status, message)
+	ts.recordTask(id)
+}
+
+// recordTask appends the current state of a task to the journal. The snapshot
+// is taken under the journal lock so that concurrent updates of the same task
+// are recorded in the order they were applied.
+func (ts *journalTaskStore) recordTask(id string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if _, ok := ts.Get(id); !ok {
+		return
+	}
+	snapshot := ts.snapshot(id)
+	ts.appendLocked(journalRecord{Op: "put", Task: &snapshot})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournalTaskStore_Restore(t *testing.T) {
+	stateDir := t.TempDir()
+
+	ts, err := NewJournalTaskStore(stateDir, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ts.Create("Done-1", "Upgrade")
+	ts.SetStatus("Done-1", "running", "...")
+	ts.SetStatus("Done-1", "completed", "all good")
+	ts.Create("Running-1", "Reboot")
+	ts.SetStatus("Running-1", "running", "...")
+	ts.Create("Gone-1", "Upgrade")
+	ts.Delete("Gone-1")
+
+	// Simulate a restart by opening the same state directory again.
+	restored, err := NewJournalTaskStore(stateDir, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(restored.ListTasks()) != 2 {
+		t.Fatalf("expected 2 restored tasks, got %d", len(restored.ListTasks()))
+	}
+
+	done, ok := restored.Get("done-1")
+	if !ok {
+		t.Fatal("expected completed task to be restored")
+	}
+	if done.Status != "completed" || done.Message != "all good" || done.ToolName != "Upgrade" {
+		t.Errorf("unexpected restored task: %+v", done)
+	}
+
+	running, ok := restored.Get("Running-1")
+	if !ok {
+		t.Fatal("expected running task to be restored")
+	}
+	if running.Status != "failed" || running.Message != interruptedMessage {
+		t.Errorf("expected interrupted task to be failed, got %s: %s", running.Status, running.Message)
+	}
+	select {
+	case <-running.Done():
+	default:
+		t.Error("expected Done channel of restored task to be closed")
+	}
+	if !strings.Contains(running.FormatStatus(), interruptedMessage) {
+		t.Errorf("unexpected status: %s", running.FormatStatus())
+	}
+
+	if _, ok := restored.Get("Gone-1"); ok {
+		t.Error("deleted task should not be restored")
+	}
+	if restored.HasActiveTask("Reboot") {
+		t.Error("interrupted task should not block new runs")
+	}
+}
+
+func TestJournalTaskStore_Limit(t *testing.T) {
+	stateDir := t.TempDir()
+
+	ts, err := NewJournalTaskStore(stateDir, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("job-%d", i)
+		ts.Create(id, "Tool")
+		ts.SetStatus(id, "completed", "done")
+	}
+
+	restored, err := NewJournalTaskStore(stateDir, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(restored.ListTasks()) != 3 {
+		t.Errorf("expected the store to be trimmed to 3 tasks, got %d", len(restored.ListTasks()))
+	}
+}
+
+func TestJournalTaskStore_TruncatedRecord(t *testing.T) {
+	stateDir := t.TempDir()
+
+	ts, err := NewJournalTaskStore(stateDir, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ts.Create("job-1", "Tool")
+	ts.SetStatus("job-1", "completed", "done")
+
+	// A crash in the middle of a write leaves a partial last line behind.
+	file, err := os.OpenFile(filepath.Join(stateDir, journalFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"op":"put","task":{"id":"job-2","sta`)
+	file.Close()
+
+	restored, err := NewJournalTaskStore(stateDir, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := restored.Get("job-1"); !ok {
+		t.Error("expected intact task to be restored")
+	}
+	if len(restored.ListTasks()) != 1 {
+		t.Errorf("expected 1 task, got %d", len(restored.ListTasks()))
+	}
+}
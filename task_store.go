@@ -11,6 +11,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -19,24 +20,142 @@ import (
 
 // AsyncTask represents the state of a single background job.
 type AsyncTask struct {
-	ID        string
-	ToolName  string
-	Status    string // "pending", "running", "completed", "failed"
-	Message   string // Final output or error message
-	StartTime time.Time
-	EndTime   time.Time
+	ID        string      `json:"id"`
+	ToolName  string      `json:"toolName"`
+	Status    string      `json:"status"`  // "pending", "running", "completed", "failed", "cancelled"
+	Message   string      `json:"message"` // Final output or error message
+	StartTime time.Time   `json:"startTime"`
+	EndTime   time.Time   `json:"endTime,omitempty"`
+	Output    *TaskOutput `json:"-"` // Output captured while the task is running
+
+	done chan struct{} // Closed when the task reaches a final state
+}
+
+// isFinished reports whether status is a final state.
+func isFinished(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+// Done returns a channel that is closed when the task has finished.
+func (t *AsyncTask) Done() <-chan struct{} {
+	return t.done
+}
+
+// outputTailLines is the number of lines of live output shown in the status
+// of a running task.
+const outputTailLines = 20
+
+// TaskOutput accumulates the combined stdout/stderr of a running task. It is
+// written to by the executor and read concurrently by status requests.
+type TaskOutput struct {
+	mu  sync.Mutex
+	buf outputBuffer
+}
+
+// limit bounds the output kept from the task to the output limit of item,
+// like for a tool call. It must be called before the task starts.
+func (o *TaskOutput) limit(item ContextItem) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = *newItemOutput(item)
+}
+
+// Write appends command output. It implements io.Writer.
+func (o *TaskOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+// Len returns the number of bytes written so far, including truncated
+// output.
+func (o *TaskOutput) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return int(o.buf.Len())
+}
+
+// String returns the output kept so far.
+func (o *TaskOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// Tail returns at most the last n lines of the output captured so far.
+func (o *TaskOutput) Tail(n int) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	data := strings.TrimRight(o.buf.String(), "\n")
+	start := len(data)
+	for i := 0; i < n; i++ {
+		idx := strings.LastIndexByte(data[:start], '\n')
+		if idx < 0 {
+			return data
+		}
+		start = idx
+	}
+	return data[start+1:]
+}
+
+// Close closes the file with the full output, if it was spilled.
+func (o *TaskOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Close()
+}
+
+// remove deletes the file with the full output, if it was spilled.
+func (o *TaskOutput) remove() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.remove()
 }
 
 // TaskStore is a thread-safe registry for managing async tasks.
-type TaskStore struct {
+type TaskStore interface {
+	// PrepareSlot ensures there is room for a new task in the store.
+	// If the store is full, it tries to find the oldest finished task to evict.
+	// It returns the ID of the evicted task if successful, or an empty string if no eviction was needed.
+	// It returns an error if the store is full and no task can be evicted (all tasks are active).
+	PrepareSlot() (string, error)
+	// Create initializes a new task in the "pending" state.
+	Create(id string, toolName string) *AsyncTask
+	// Delete removes a task from the store.
+	Delete(id string)
+	// Get looks up a task by its case-insensitive ID.
+	Get(id string) (*AsyncTask, bool)
+	// SetStatus updates the state and output message of a task.
+	SetStatus(id string, status string, message string)
+	// SetCancelFunc registers the function that stops the command of a task.
+	SetCancelFunc(id string, cancel context.CancelFunc)
+	// Cancel stops the command of an active task.
+	Cancel(id string) (*AsyncTask, error)
+	// ListTasks returns all tasks in the store.
+	ListTasks() []*AsyncTask
+	// ListActiveTasks returns all currently pending or running tasks.
+	ListActiveTasks() []*AsyncTask
+	// HasActiveTask checks if a specific tool type is already running.
+	HasActiveTask(toolName string) bool
+}
+
+// memoryTaskStore is the default TaskStore, which keeps tasks in memory only.
+type memoryTaskStore struct {
 	mu       sync.RWMutex
 	tasks    map[string]*AsyncTask
+	cancels  map[string]context.CancelFunc
 	maxTasks int
 }
 
-func NewTaskStore(maxTasks int) *TaskStore {
-	return &TaskStore{
+// NewTaskStore creates an in-memory task store holding at most maxTasks tasks.
+func NewTaskStore(maxTasks int) TaskStore {
+	return newMemoryTaskStore(maxTasks)
+}
+
+func newMemoryTaskStore(maxTasks int) *memoryTaskStore {
+	return &memoryTaskStore{
 		tasks:    make(map[string]*AsyncTask),
+		cancels:  make(map[string]context.CancelFunc),
 		maxTasks: maxTasks,
 	}
 }
@@ -45,7 +164,7 @@ func NewTaskStore(maxTasks int) *TaskStore {
 // If the store is full, it tries to find the oldest finished task to evict.
 // It returns the ID of the evicted task if successful, or an empty string if no eviction was needed.
 // It returns an error if the store is full and no task can be evicted (all tasks are active).
-func (ts *TaskStore) PrepareSlot() (string, error) {
+func (ts *memoryTaskStore) PrepareSlot() (string, error) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
@@ -55,7 +174,7 @@ func (ts *TaskStore) PrepareSlot() (string, error) {
 
 	var oldestTask *AsyncTask
 	for _, task := range ts.tasks {
-		if task.Status == "completed" || task.Status == "failed" {
+		if isFinished(task.Status) {
 			if oldestTask == nil || task.EndTime.Before(oldestTask.EndTime) {
 				oldestTask = task
 			}
@@ -70,7 +189,7 @@ func (ts *TaskStore) PrepareSlot() (string, error) {
 }
 
 // Create initializes a new task in the "pending" state.
-func (ts *TaskStore) Create(id string, toolName string) *AsyncTask {
+func (ts *memoryTaskStore) Create(id string, toolName string) *AsyncTask {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
@@ -80,19 +199,29 @@ func (ts *TaskStore) Create(id string, toolName string) *AsyncTask {
 		Status:    "pending",
 		Message:   "Job has been queued.",
 		StartTime: time.Now(),
+		Output:    &TaskOutput{},
+		done:      make(chan struct{}),
 	}
 	ts.tasks[strings.ToLower(id)] = task
 	return task
 }
 
 // Delete removes a task from the store.
-func (ts *TaskStore) Delete(id string) {
+func (ts *memoryTaskStore) Delete(id string) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
-	delete(ts.tasks, id)
+	key := strings.ToLower(id)
+	if task, ok := ts.tasks[key]; ok {
+		task.Output.remove()
+	}
+	delete(ts.tasks, key)
+	if cancel, ok := ts.cancels[key]; ok {
+		cancel()
+		delete(ts.cancels, key)
+	}
 }
 
-func (ts *TaskStore) Get(id string) (*AsyncTask, bool) {
+func (ts *memoryTaskStore) Get(id string) (*AsyncTask, bool) {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
 
@@ -101,7 +230,7 @@ func (ts *TaskStore) Get(id string) (*AsyncTask, bool) {
 }
 
 // SetStatus updates the state and output message of a task.
-func (ts *TaskStore) SetStatus(id string, status string, message string) {
+func (ts *memoryTaskStore) SetStatus(id string, status string, message string) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
@@ -110,16 +239,65 @@ func (ts *TaskStore) SetStatus(id string, status string, message string) {
 		return
 	}
 
+	wasFinished := isFinished(task.Status)
 	task.Status = status
 	task.Message = message
-	if status == "completed" || status == "failed" {
+	if isFinished(status) {
 		task.EndTime = time.Now()
+		if !wasFinished {
+			close(task.done)
+		}
+		// Release the context of the finished command.
+		if cancel, ok := ts.cancels[strings.ToLower(id)]; ok {
+			cancel()
+			delete(ts.cancels, strings.ToLower(id))
+		}
 	}
 }
 
+// SetCancelFunc registers the function that stops the command of a task.
+func (ts *memoryTaskStore) SetCancelFunc(id string, cancel context.CancelFunc) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.cancels[strings.ToLower(id)] = cancel
+}
+
+// Cancel stops the command of an active task. The task itself is moved to the
+// "cancelled" state by its goroutine once the command has exited.
+func (ts *memoryTaskStore) Cancel(id string) (*AsyncTask, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	task, ok := ts.tasks[strings.ToLower(id)]
+	if !ok {
+		return nil, fmt.Errorf("No task found with ID: %s", id)
+	}
+	if isFinished(task.Status) {
+		return task, fmt.Errorf("Task %s has already finished with status: %s", task.ID, task.Status)
+	}
+	cancel, ok := ts.cancels[strings.ToLower(id)]
+	if !ok {
+		return task, fmt.Errorf("Task %s cannot be cancelled", task.ID)
+	}
+	cancel()
+	return task, nil
+}
+
+// ListTasks returns a slice of all tasks in the store.
+func (ts *memoryTaskStore) ListTasks() []*AsyncTask {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]*AsyncTask, 0, len(ts.tasks))
+	for _, task := range ts.tasks {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
 // ListActiveTasks returns a slice of all currently pending or running tasks.
 // This powers the 'ListPendingTasks' tool, helping the LLM recover lost task IDs.
-func (ts *TaskStore) ListActiveTasks() []*AsyncTask {
+func (ts *memoryTaskStore) ListActiveTasks() []*AsyncTask {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
 
@@ -134,7 +312,7 @@ func (ts *TaskStore) ListActiveTasks() []*AsyncTask {
 
 // HasActiveTask checks if a specific tool type is already running.
 // Used to implement a concurrency lock (e.g., preventing parallel upgrades).
-func (ts *TaskStore) HasActiveTask(toolName string) bool {
+func (ts *memoryTaskStore) HasActiveTask(toolName string) bool {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
 
@@ -149,7 +327,7 @@ func (ts *TaskStore) HasActiveTask(toolName string) bool {
 // FormatStatus returns a human-readable summary of the task.
 func (t *AsyncTask) FormatStatus() string {
 	var duration time.Duration
-	if t.Status == "completed" || t.Status == "failed" {
+	if isFinished(t.Status) {
 		if !t.EndTime.IsZero() {
 			duration = t.EndTime.Sub(t.StartTime)
 		}
@@ -163,7 +341,13 @@ func (t *AsyncTask) FormatStatus() string {
 		return fmt.Sprintf("Status: %s\nCompleted In: %s\nOutput: %s", t.Status, durationStr, t.Message)
 	case "failed":
 		return fmt.Sprintf("Status: %s\nFailed After: %s\nError: %s", t.Status, durationStr, t.Message)
+	case "cancelled":
+		return fmt.Sprintf("Status: %s\nCancelled After: %s\nMessage: %s", t.Status, durationStr, t.Message)
 	default:
-		return fmt.Sprintf("Status: %s\nRunning For: %s\nMessage: %s", t.Status, durationStr, t.Message)
+		status := fmt.Sprintf("Status: %s\nRunning For: %s\nMessage: %s", t.Status, durationStr, t.Message)
+		if t.Output != nil && t.Output.Len() > 0 {
+			status += fmt.Sprintf("\nOutput So Far: %d bytes\nRecent Output (last %d lines):\n%s", t.Output.Len(), outputTailLines, t.Output.Tail(outputTailLines))
+		}
+		return status
 	}
 }
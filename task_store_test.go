@@ -137,3 +137,82 @@ func TestTaskStore_Vacuum(t *testing.T) {
 		t.Errorf("expected evicted task 2, got %s", evictedID)
 	}
 }
+
+func TestTaskOutput_Tail(t *testing.T) {
+	out := &TaskOutput{}
+	if out.Tail(3) != "" {
+		t.Errorf("expected empty tail, got %q", out.Tail(3))
+	}
+
+	out.Write([]byte("one\ntwo\n"))
+	out.Write([]byte("three\nfour\n"))
+	if got := out.Tail(2); got != "three\nfour" {
+		t.Errorf("expected last two lines, got %q", got)
+	}
+	if got := out.Tail(10); got != "one\ntwo\nthree\nfour" {
+		t.Errorf("expected all lines, got %q", got)
+	}
+	if out.Len() != len("one\ntwo\nthree\nfour\n") {
+		t.Errorf("unexpected length %d", out.Len())
+	}
+}
+
+func TestTaskStore_FormatStatusLiveOutput(t *testing.T) {
+	ts := NewTaskStore(10)
+	task := ts.Create("1", "Upgrade")
+	ts.SetStatus("1", "running", "Job is executing...")
+
+	if strings.Contains(task.FormatStatus(), "Recent Output") {
+		t.Error("did not expect output section before any output was produced")
+	}
+
+	task.Output.Write([]byte("Downloading packages...\nInstalling 1/20\n"))
+	status := task.FormatStatus()
+	if !strings.Contains(status, "Recent Output") || !strings.Contains(status, "Installing 1/20") {
+		t.Errorf("expected live output in status, got %q", status)
+	}
+}
+
+func TestTaskStore_Cancel(t *testing.T) {
+	ts := NewTaskStore(1)
+	task := ts.Create("Job-1", "Upgrade")
+	ts.SetStatus("Job-1", "running", "...")
+
+	cancelled := false
+	ts.SetCancelFunc("Job-1", func() { cancelled = true })
+
+	if _, err := ts.Cancel("job-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cancelled {
+		t.Error("expected the cancel function to be called")
+	}
+
+	// The job goroutine records the final state once the command has exited.
+	ts.SetStatus("Job-1", "cancelled", "partial output")
+	select {
+	case <-task.Done():
+	default:
+		t.Error("expected Done channel to be closed")
+	}
+	if ts.HasActiveTask("Upgrade") {
+		t.Error("cancelled task should not be active")
+	}
+	if !strings.Contains(task.FormatStatus(), "Status: cancelled") {
+		t.Errorf("unexpected status: %s", task.FormatStatus())
+	}
+
+	// The slot of the cancelled task can be reused.
+	evictID, err := ts.PrepareSlot()
+	if err != nil || evictID != "Job-1" {
+		t.Errorf("expected cancelled task to be evictable, got %q, %v", evictID, err)
+	}
+	ts.Delete(evictID)
+	if _, ok := ts.Get("Job-1"); ok {
+		t.Error("expected evicted task to be deleted")
+	}
+
+	if _, err := ts.Cancel("Job-1"); err == nil {
+		t.Error("expected error when cancelling an unknown task")
+	}
+}
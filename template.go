@@ -0,0 +1,177 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides parameterized resources. A resource with a
+// uriTemplate (RFC 6570) is registered as an MCP resource template, and the
+// variables extracted from the URI of a read are validated and passed to its
+// command like the parameters of a tool.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/yosida95/uritemplate/v3"
+)
+
+// errNoTemplateMatch is returned for URIs that do not match a URI template.
+var errNoTemplateMatch = errors.New("the URI does not match the template")
+
+// id returns the URI of the resource, or its URI template.
+func (r ResourceItem) id() string {
+	if r.URITemplate != "" {
+		return r.URITemplate
+	}
+	return r.URI
+}
+
+// validateTemplate checks the URI template of a resource and its variables,
+// and prepares the variables for validating the values.
+func (r *ResourceItem) validateTemplate() error {
+	if r.URITemplate == "" {
+		if len(r.Variables) > 0 {
+			return fmt.Errorf("variables require a uriTemplate")
+		}
+		return nil
+	}
+	if r.URI != "" {
+		return fmt.Errorf("'uri' and 'uriTemplate' are mutually exclusive")
+	}
+	if r.Directory != "" {
+		return fmt.Errorf("a directory cannot have a uriTemplate")
+	}
+	if r.Command == "" && len(r.Args) == 0 {
+		return fmt.Errorf("a uriTemplate requires a command or args")
+	}
+	tmpl, err := uritemplate.New(r.URITemplate)
+	if err != nil {
+		return fmt.Errorf("invalid uriTemplate: %w", err)
+	}
+	names := make(map[string]bool)
+	for _, name := range tmpl.Varnames() {
+		names[name] = true
+	}
+	for i := range r.Variables {
+		if err := r.Variables[i].compile(); err != nil {
+			return err
+		}
+		if !names[r.Variables[i].Name] {
+			return fmt.Errorf("variable %s is not in the uriTemplate", r.Variables[i].Name)
+		}
+	}
+	return nil
+}
+
+// expandTemplate returns the resource at uri, which matches the URI template
+// of r. The variables are validated and passed to the command.
+func (r ResourceItem) expandTemplate(uri string) (ResourceItem, error) {
+	tmpl, err := uritemplate.New(r.URITemplate)
+	if err != nil {
+		return ResourceItem{}, err
+	}
+	match := tmpl.Match(uri)
+	if match == nil {
+		return ResourceItem{}, errNoTemplateMatch
+	}
+	args := make(map[string]any, len(match))
+	for name, value := range match {
+		if value.T == uritemplate.ValueTypeString {
+			args[name] = value.String()
+		} else {
+			args[name] = strings.Join(value.V, ",")
+		}
+	}
+	validated, err := validateArguments(r.Variables, args)
+	if err != nil {
+		return ResourceItem{}, err
+	}
+	// Variables without a definition are passed as they are.
+	vars := make(map[string]interface{}, len(args))
+	for name, value := range args {
+		vars[name] = value
+	}
+	for name, value := range validated {
+		vars[name] = value
+	}
+
+	item := r
+	item.URI = uri
+	item.vars = vars
+	return item, nil
+}
+
+// hasTemplate reports whether the resource is served by a resource template:
+// a resource with a uriTemplate, or the files of a directory.
+func (r ResourceItem) hasTemplate() bool {
+	return r.URITemplate != "" || r.Directory != ""
+}
+
+// configTemplate builds the resource template and handler for a resource
+// with a uriTemplate or a directory. It reports false for other resources.
+func configTemplate(currentItem ResourceItem, auditLog *AuditLog, tmpDir string, verbose bool) (server.ServerResourceTemplate, bool) {
+	switch {
+	case currentItem.Directory != "":
+		return directoryTemplate(currentItem, auditLog, tmpDir, verbose), true
+	case currentItem.URITemplate != "":
+		return uriTemplate(currentItem, auditLog, tmpDir, verbose), true
+	}
+	return server.ServerResourceTemplate{}, false
+}
+
+// uriTemplate builds the resource template and handler for a resource with a
+// uriTemplate.
+func uriTemplate(currentItem ResourceItem, auditLog *AuditLog, tmpDir string, verbose bool) server.ServerResourceTemplate {
+	template := mcp.NewResourceTemplate(
+		currentItem.URITemplate,
+		currentItem.Description,
+		mcp.WithTemplateDescription(currentItem.Description),
+		mcp.WithTemplateMIMEType(currentItem.mimeType()),
+	)
+
+	handler := func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		if verbose {
+			log.Printf("Handling resource read request for: %s", request.Params.URI)
+		}
+		item, err := currentItem.expandTemplate(request.Params.URI)
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %w", request.Params.URI, err)
+		}
+		content, err := getResourceContent(ctx, item, auditLog, tmpDir, verbose)
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{resourceContents(request.Params.URI, item, content)}, nil
+	}
+
+	return server.ServerResourceTemplate{Template: template, Handler: handler}
+}
+
+// outputTemplate builds the resource template and handler for the full
+// output of truncated commands, which is kept in spilledOutputs.
+func outputTemplate() server.ServerResourceTemplate {
+	template := mcp.NewResourceTemplate(
+		outputURIPrefix+"{name}",
+		"Full output of a truncated command",
+		mcp.WithTemplateDescription("Full output of a truncated command"),
+		mcp.WithTemplateMIMEType("text/plain"),
+	)
+
+	handler := func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		content, err := spilledOutputs.read(strings.TrimPrefix(request.Params.URI, outputURIPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %w", request.Params.URI, err)
+		}
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, MIMEType: "text/plain", Text: content}}, nil
+	}
+
+	return server.ServerResourceTemplate{Template: template, Handler: handler}
+}
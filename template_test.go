@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestResourceTemplate(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "simple-mcp.yaml", `apiVersion: v1
+kind: DynamicContextSource
+spec:
+  resources:
+    - uri: "test://static"
+      content: "static"
+    - uriTemplate: "test://unit/{name}"
+      description: "Unit status"
+      command: "echo unit={{.name}}"
+      variables:
+        - name: name
+          pattern: '^[a-z.]+$'
+    - uriTemplate: "test://files{/path*}"
+      args: ["echo", "{{.path}}"]
+      roles: [admin]
+  roles:
+    - name: admin
+      members: [alice]
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	registry := NewRegistry(cfg, NewTaskStore(10))
+	mcpServer := server.NewMCPServer("test", "v1", server.WithResourceCapabilities(true, true))
+	registerResources(mcpServer, cfg, nil, dir, false)
+
+	readResource := func(uri string) string {
+		t.Helper()
+		message, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0", "id": 1, "method": "resources/read",
+			"params": map[string]any{"uri": uri},
+		})
+		response, _ := json.Marshal(mcpServer.HandleMessage(context.Background(), message))
+		return string(response)
+	}
+	if response := readResource("test://unit/sshd.service"); !strings.Contains(response, `"text":"unit=sshd.service\n"`) {
+		t.Errorf("expected the output of the command, got %s", response)
+	}
+	if response := readResource("test://unit/%24%28reboot%29"); !strings.Contains(response, "invalid value for parameter 'name'") {
+		t.Errorf("expected the variable to be rejected, got %s", response)
+	}
+	if response := readResource("test://files/a/b"); !strings.Contains(response, `"text":"a,b\n"`) {
+		t.Errorf("expected the list variable, got %s", response)
+	}
+
+	// The built-in tools and the access policy know the template resources.
+	resources := registry.Resources()
+	if _, ok := resources["test://unit/{name}"]; !ok {
+		t.Errorf("expected the registry to hold the template, got %v", resources)
+	}
+	if item, ok := lookupResource(resources, "test://unit/cron.service"); !ok || item.vars["name"] != "cron.service" {
+		t.Errorf("expected the template to match, got %+v", item)
+	}
+	if _, ok := lookupResource(resources, "test://unit/{name}"); ok {
+		t.Error("expected the template itself not to be a resource")
+	}
+	alice := withPrincipal(context.Background(), &Principal{Name: "alice"})
+	bob := withPrincipal(context.Background(), &Principal{Name: "bob"})
+	if policy := registry.Policy(); policy.CanReadResource(bob, "test://files/a") || !policy.CanReadResource(alice, "test://files/a") || !policy.CanReadResource(bob, "test://unit/x") {
+		t.Error("resources of a template should follow the roles of the template")
+	}
+}
+
+func TestLoadConfig_ResourceTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		err      string
+	}{
+		{"uri and template", `{uri: "test://a", uriTemplate: "test://{a}", command: "true"}`, "mutually exclusive"},
+		{"invalid template", `{uriTemplate: "test://{a", command: "true"}`, "invalid uriTemplate"},
+		{"no command", `{uriTemplate: "test://{a}", content: "a"}`, "requires a command"},
+		{"unknown variable", `{uriTemplate: "test://{a}", command: "true", variables: [{name: b}]}`, "variable b is not in the uriTemplate"},
+		{"invalid pattern", `{uriTemplate: "test://{a}", command: "true", variables: [{name: a, pattern: "("}]}`, "invalid pattern"},
+		{"variables without template", `{uri: "test://a", command: "true", variables: [{name: a}]}`, "variables require a uriTemplate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", "apiVersion: v1\nkind: DynamicContextSource\nspec:\n  resources:\n    - "+tt.resource+"\n")
+			if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
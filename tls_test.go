@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA if
+// parent is nil.
+func newTestCert(t *testing.T, cn string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"Example"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+
+	ca := newTestCert(t, "Test CA", nil, 0)
+	serverCert := newTestCert(t, "server-1", ca, x509.ExtKeyUsageServerAuth)
+	clientCert := newTestCert(t, "alice", ca, x509.ExtKeyUsageClientAuth)
+	os.WriteFile(certFile, serverCert.certPEM, 0600)
+	os.WriteFile(keyFile, serverCert.keyPEM, 0600)
+	os.WriteFile(caFile, ca.certPEM, 0600)
+
+	certs, err := newCertReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(clientCertMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, principalName(r.Context()))
+	})))
+	srv.TLS = certs.tlsConfig()
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientKeyPair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(withClientCert bool) (string, string, error) {
+		tlsConfig := &tls.Config{RootCAs: roots}
+		if withClientCert {
+			tlsConfig.Certificates = []tls.Certificate{clientKeyPair}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return "", "", err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+	}
+
+	principal, serverCN, err := get(true)
+	if err != nil {
+		t.Fatalf("request with client certificate failed: %v", err)
+	}
+	if principal != "CN=alice,O=Example" {
+		t.Errorf("expected the certificate subject as principal, got %q", principal)
+	}
+	if serverCN != "server-1" {
+		t.Errorf("unexpected server certificate %s", serverCN)
+	}
+
+	// HTTP/2 is negotiated with ALPN.
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientKeyPair}},
+		ForceAttemptHTTP2: true,
+	}}
+	if resp, err := client.Get(srv.URL); err != nil {
+		t.Errorf("request over HTTP/2 failed: %v", err)
+	} else {
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2, got %s", resp.Proto)
+		}
+	}
+
+	if _, _, err := get(false); err == nil {
+		t.Error("expected request without client certificate to fail")
+	}
+
+	// Renew the server certificate and reload it on the running listener.
+	renewed := newTestCert(t, "server-2", ca, x509.ExtKeyUsageServerAuth)
+	os.WriteFile(certFile, renewed.certPEM, 0600)
+	os.WriteFile(keyFile, renewed.keyPEM, 0600)
+	if err := certs.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if _, serverCN, err = get(true); err != nil || serverCN != "server-2" {
+		t.Errorf("expected renewed certificate, got %s (%v)", serverCN, err)
+	}
+
+	// A broken file must not replace the working certificate.
+	os.WriteFile(certFile, []byte("garbage"), 0600)
+	if err := certs.reload(); err == nil {
+		t.Error("expected reload of invalid certificate to fail")
+	}
+	if _, serverCN, err = get(true); err != nil || serverCN != "server-2" {
+		t.Errorf("expected previous certificate to stay in use, got %s (%v)", serverCN, err)
+	}
+}
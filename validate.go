@@ -0,0 +1,509 @@
+// Copyright (c) 2025 Vojtech Pavlik <vojtech@suse.com>
+//
+// Created using AI tools
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package main provides the checks behind `simple-mcp validate`. Unlike
+// LoadConfig, which stops at the first error, the validator goes through the
+// whole configuration, including the included files, and reports every
+// problem with the file and line it was found at. It also catches mistakes
+// that LoadConfig cannot see, such as command templates that fail to parse or
+// refer to undeclared parameters.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/yosida95/uritemplate/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configAPIVersion = "v1"
+	configKind       = "DynamicContextSource"
+)
+
+// Problem is an issue found in a configuration file.
+type Problem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+// location identifies where a tool or resource was defined.
+type location struct {
+	file string
+	line int
+}
+
+func (l location) String() string {
+	return fmt.Sprintf("%s:%d", l.file, l.line)
+}
+
+type validator struct {
+	problems  []Problem
+	tools     map[string]location
+	resources map[string]location
+	spec      Spec // Everything found so far, for the role checks
+}
+
+// ValidateConfig checks the configuration file at path and the files it
+// includes and returns all problems found, in the order of the files.
+func ValidateConfig(path string) []Problem {
+	v := &validator{
+		tools:     make(map[string]location),
+		resources: make(map[string]location),
+	}
+	v.validateMain(path)
+
+	// Report the problems of each file in the order of their lines.
+	order := make(map[string]int)
+	for _, problem := range v.problems {
+		if _, ok := order[problem.File]; !ok {
+			order[problem.File] = len(order)
+		}
+	}
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		a, b := v.problems[i], v.problems[j]
+		if a.File != b.File {
+			return order[a.File] < order[b.File]
+		}
+		return a.Line < b.Line
+	})
+
+	if len(v.problems) == 0 {
+		if err := validateRoles(&v.spec); err != nil {
+			v.add(path, 0, "%v", err)
+		}
+	}
+
+	// Anything LoadConfig rejects must be reported, even if the checks above
+	// missed it.
+	if len(v.problems) == 0 {
+		if _, err := LoadConfig(path); err != nil {
+			v.add(path, 0, "%v", err)
+		}
+	}
+	return v.problems
+}
+
+// add records a problem. Type errors are found both when decoding a whole
+// file and when decoding its items, so each problem is only recorded once.
+func (v *validator) add(file string, line int, format string, args ...interface{}) {
+	problem := Problem{File: file, Line: line, Message: fmt.Sprintf(format, args...)}
+	for _, p := range v.problems {
+		if p == problem {
+			return
+		}
+	}
+	v.problems = append(v.problems, problem)
+}
+
+// parseFile reads a file and returns its top-level YAML node, or nil if there
+// is nothing to check.
+func (v *validator) parseFile(path string) *yaml.Node {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.add(path, 0, "%v", err)
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		line := 0
+		if matches := yamlLineRegex.FindStringSubmatch(err.Error()); len(matches) == 2 {
+			line, _ = strconv.Atoi(matches[1])
+		}
+		v.add(path, line, "%v", err)
+		return nil
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	if _, err := interpolate(&doc, filepath.Dir(path)); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			v.addTypeErrors(path, &doc, typeErr.Errors)
+		}
+	}
+	return doc.Content[0]
+}
+
+// decode decodes node into out and reports unknown keys and type errors with
+// their lines. Unknown keys do not make it fail, so that the rest of the item
+// is still checked.
+func (v *validator) decode(path string, node *yaml.Node, out interface{}) bool {
+	v.addTypeErrors(path, node, unknownFields(node, reflect.TypeOf(out)))
+	err := node.Decode(out)
+	if err == nil {
+		return true
+	}
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		v.add(path, node.Line, "%v", err)
+		return false
+	}
+	v.addTypeErrors(path, node, typeErr.Errors)
+	return false
+}
+
+// addTypeErrors records the messages of a yaml.TypeError, which start with the
+// line they refer to.
+func (v *validator) addTypeErrors(path string, node *yaml.Node, errs []string) {
+	for _, msg := range errs {
+		line := node.Line
+		if matches := yamlLineRegex.FindStringSubmatch(msg); len(matches) == 2 {
+			line, _ = strconv.Atoi(matches[1])
+			msg = strings.TrimSpace(strings.TrimPrefix(msg, matches[0]))
+		}
+		v.add(path, line, "%s", msg)
+	}
+}
+
+func (v *validator) validateMain(path string) {
+	root := v.parseFile(path)
+	if root == nil {
+		if len(v.problems) == 0 {
+			v.add(path, 0, "the configuration is empty")
+		}
+		return
+	}
+
+	var cfg Config
+	v.decode(path, root, &cfg)
+	v.spec = cfg.Specification
+	v.spec.Tools, v.spec.Resources, v.spec.LegacyItems = nil, nil, nil
+
+	v.checkValue(path, root, "apiVersion", cfg.APIVersion, configAPIVersion)
+	v.checkValue(path, root, "kind", cfg.Kind, configKind)
+
+	spec := mappingValue(root, "spec")
+	if spec == nil {
+		v.add(path, root.Line, "'spec' is missing")
+		return
+	}
+	tools := mappingValue(spec, "tools")
+	if legacy := mappingValue(spec, "contextItems"); legacy != nil {
+		if tools != nil {
+			v.add(path, legacy.Line, "both 'contextItems' and 'tools' are defined in 'spec', please use only 'tools'")
+		} else {
+			tools = legacy
+		}
+	}
+	v.checkSetting(path, spec, "environment", "", cfg.Specification.Environment.validate())
+	v.checkSetting(path, spec, "limits", "", cfg.Specification.Limits.validate())
+	v.checkSetting(path, spec, "maxOutputBytes", "", checkMaxOutputBytes(cfg.Specification.MaxOutputBytes))
+	v.validateItems(path, tools, mappingValue(spec, "resources"))
+
+	// Check each include pattern on its own, so that one bad pattern does
+	// not hide the problems in the other files.
+	var patterns []string
+	if include := mappingValue(spec, "include"); include != nil {
+		for i, pattern := range cfg.Specification.Include {
+			line := include.Line
+			if i < len(include.Content) {
+				line = include.Content[i].Line
+			}
+			if _, err := matchInclude(path, pattern); err != nil {
+				v.add(path, line, "%v", err)
+			} else {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	files, _ := includedFiles(path, patterns)
+	for _, file := range files {
+		if root := v.parseFile(file); root != nil {
+			var fragment ConfigFragment
+			v.decode(file, root, &fragment)
+			v.validateItems(file, mappingValue(root, "tools"), mappingValue(root, "resources"))
+		}
+	}
+}
+
+// checkSetting reports the error of the setting under key of the mapping node.
+func (v *validator) checkSetting(path string, node *yaml.Node, key, prefix string, err error) {
+	if err != nil {
+		v.add(path, mappingValue(node, key).Line, "%s%v", prefix, err)
+	}
+}
+
+// checkCredential reports unknown users and groups of the mapping node.
+func (v *validator) checkCredential(path string, node *yaml.Node, prefix string) {
+	if value := mappingValue(node, "user"); value != nil && value.Kind == yaml.ScalarNode {
+		if _, err := lookupUser(value.Value); err != nil {
+			v.add(path, value.Line, "%s%v", prefix, err)
+		}
+	}
+	groups := []*yaml.Node{mappingValue(node, "group")}
+	if value := mappingValue(node, "supplementaryGroups"); value != nil {
+		groups = append(groups, value.Content...)
+	}
+	for _, value := range groups {
+		if value != nil && value.Kind == yaml.ScalarNode {
+			if _, err := lookupGroup(value.Value); err != nil {
+				v.add(path, value.Line, "%s%v", prefix, err)
+			}
+		}
+	}
+}
+
+// checkValue reports a missing or unexpected value of a top-level key.
+func (v *validator) checkValue(path string, root *yaml.Node, key, value, want string) {
+	node := mappingValue(root, key)
+	if node == nil {
+		v.add(path, root.Line, "'%s' is missing, expected %q", key, want)
+	} else if value != want {
+		v.add(path, node.Line, "unsupported %s %q, expected %q", key, value, want)
+	}
+}
+
+func (v *validator) validateItems(path string, tools, resources *yaml.Node) {
+	if tools != nil {
+		for _, node := range tools.Content {
+			v.validateTool(path, node)
+		}
+	}
+	if resources != nil {
+		for _, node := range resources.Content {
+			v.validateResource(path, node)
+		}
+	}
+}
+
+func (v *validator) validateTool(path string, node *yaml.Node) {
+	var item ContextItem
+	if !v.decode(path, node, &item) {
+		return
+	}
+	v.spec.Tools = append(v.spec.Tools, item)
+
+	if item.Name == "" {
+		v.add(path, node.Line, "tool without a name")
+	} else if first, ok := v.tools[item.Name]; ok {
+		v.add(path, node.Line, "tool %s is already defined at %s", item.Name, first)
+	} else {
+		v.tools[item.Name] = location{path, node.Line}
+	}
+
+	if item.Command == "" && len(item.Args) == 0 {
+		v.add(path, node.Line, "tool %s: neither 'command' nor 'args' is set", item.Name)
+	}
+	if item.Command != "" && len(item.Args) > 0 {
+		v.add(path, node.Line, "tool %s: 'command' and 'args' are mutually exclusive", item.Name)
+	}
+
+	declared := make(map[string]bool)
+	if params := mappingValue(node, "parameters"); params != nil {
+		for i := range item.Parameters {
+			param := &item.Parameters[i]
+			declared[param.Name] = true
+			line := params.Line
+			if i < len(params.Content) {
+				line = params.Content[i].Line
+			}
+			if err := param.compile(); err != nil {
+				v.add(path, line, "tool %s: %v", item.Name, err)
+			}
+		}
+	}
+	if err := checkEnvNames(item.Env); err != nil {
+		line := node.Line
+		if env := mappingValue(node, "env"); env != nil {
+			line = env.Line
+		}
+		v.add(path, line, "tool %s: %v", item.Name, err)
+	}
+	v.checkSetting(path, node, "environment", "tool "+item.Name+": ", item.Environment.validate())
+	v.checkSetting(path, node, "limits", "tool "+item.Name+": ", item.Limits.validate())
+	v.checkSetting(path, node, "maxOutputBytes", "tool "+item.Name+": ", checkMaxOutputBytes(item.MaxOutputBytes))
+	v.checkSetting(path, node, "successExitCodes", "tool "+item.Name+": ", checkExitCodes(item.SuccessExitCodes))
+	v.checkCredential(path, node, "tool "+item.Name+": ")
+
+	what := "tool " + item.Name
+	if command := mappingValue(node, "command"); command != nil {
+		v.checkTemplate(path, command.Line, what+": command", item.Command, declared)
+	}
+	if args := mappingValue(node, "args"); args != nil {
+		for i, arg := range args.Content {
+			v.checkTemplate(path, arg.Line, fmt.Sprintf("%s: args[%d]", what, i), arg.Value, declared)
+		}
+	}
+}
+
+func (v *validator) validateResource(path string, node *yaml.Node) {
+	var item ResourceItem
+	if !v.decode(path, node, &item) {
+		return
+	}
+	v.spec.Resources = append(v.spec.Resources, item)
+
+	if item.id() == "" {
+		v.add(path, node.Line, "resource without a URI or URI template")
+	}
+	if item.Command != "" && len(item.Args) > 0 {
+		v.add(path, node.Line, "resource %s: 'command' and 'args' are mutually exclusive", item.id())
+	}
+	v.checkSetting(path, node, "environment", "resource "+item.id()+": ", item.Environment.validate())
+	v.checkSetting(path, node, "limits", "resource "+item.id()+": ", item.Limits.validate())
+	v.checkSetting(path, node, "maxOutputBytes", "resource "+item.id()+": ", checkMaxOutputBytes(item.MaxOutputBytes))
+	v.checkSetting(path, node, "intervalSeconds", "resource "+item.id()+": ", checkInterval(item.IntervalSeconds))
+	v.checkSetting(path, node, "mimeType", "resource "+item.id()+": ", checkMIMEType(item.MimeType))
+	v.checkCredential(path, node, "resource "+item.id()+": ")
+
+	// Resource commands only have the variables of their URI template as
+	// parameters, so any other field is a mistake.
+	declared := make(map[string]bool)
+	if item.URITemplate != "" {
+		if tmpl, err := uritemplate.New(item.URITemplate); err == nil {
+			for _, name := range tmpl.Varnames() {
+				declared[name] = true
+			}
+		}
+	}
+	key := "uriTemplate"
+	if item.URITemplate == "" {
+		key = "variables"
+	}
+	if mappingValue(node, key) != nil {
+		v.checkSetting(path, node, key, "resource "+item.id()+": ", item.validateTemplate())
+	} else if err := item.validateTemplate(); err != nil {
+		v.add(path, node.Line, "resource %s: %v", item.id(), err)
+	}
+	what := "resource " + item.id()
+	if command := mappingValue(node, "command"); command != nil {
+		v.checkTemplate(path, command.Line, what+": command", item.Command, declared)
+	}
+	if args := mappingValue(node, "args"); args != nil {
+		for i, arg := range args.Content {
+			v.checkTemplate(path, arg.Line, fmt.Sprintf("%s: args[%d]", what, i), arg.Value, declared)
+		}
+	}
+
+	resolve := func(file string) string {
+		if filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(filepath.Dir(path), file)
+	}
+
+	if item.ContentFile != "" && item.Directory == "" {
+		if _, err := os.Stat(resolve(item.ContentFile)); err != nil {
+			v.add(path, mappingValue(node, "contentFile").Line, "resource %s: content file %s does not exist", item.id(), resolve(item.ContentFile))
+		}
+	}
+	if item.Directory != "" {
+		// The files are only known when they are accessed.
+		if info, err := os.Stat(resolve(item.Directory)); err != nil {
+			v.add(path, mappingValue(node, "directory").Line, "resource %s: %v", item.id(), err)
+		} else if !info.IsDir() {
+			v.add(path, mappingValue(node, "directory").Line, "resource %s: %s is not a directory", item.id(), resolve(item.Directory))
+		}
+	}
+	if err := item.validateDirectory(); err != nil {
+		v.add(path, node.Line, "resource %s: %v", item.id(), err)
+	}
+
+	if item.id() == "" {
+		return
+	}
+	if first, ok := v.resources[item.id()]; ok {
+		v.add(path, node.Line, "resource %s is already defined at %s", item.id(), first)
+	} else {
+		v.resources[item.id()] = location{path, node.Line}
+	}
+}
+
+// checkTemplate parses a command template and reports fields that do not
+// name a declared parameter, as they would silently render as "<no value>".
+func (v *validator) checkTemplate(path string, line int, what, text string, declared map[string]bool) {
+	tmpl, err := template.New("command").Parse(text)
+	if err != nil {
+		v.add(path, line, "%s: invalid template: %v", what, err)
+		return
+	}
+	if tmpl.Tree == nil {
+		return
+	}
+	var fields []string
+	templateFields(tmpl.Tree.Root, &fields)
+	sort.Strings(fields)
+	reported := make(map[string]bool)
+	for _, field := range fields {
+		if declared[field] || reported[field] {
+			continue
+		}
+		reported[field] = true
+		v.add(path, line, "%s: template refers to {{.%s}}, which is not a declared parameter", what, field)
+	}
+}
+
+// templateFields collects the names of the fields of the template data used
+// in node. Fields inside range and with blocks refer to other data and are
+// skipped.
+func templateFields(node parse.Node, fields *[]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			templateFields(child, fields)
+		}
+	case *parse.ActionNode:
+		templateFields(n.Pipe, fields)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				templateFields(arg, fields)
+			}
+		}
+	case *parse.IfNode:
+		templateFields(n.Pipe, fields)
+		templateFields(n.List, fields)
+		templateFields(n.ElseList, fields)
+	case *parse.RangeNode:
+		templateFields(n.Pipe, fields)
+	case *parse.WithNode:
+		templateFields(n.Pipe, fields)
+	case *parse.FieldNode:
+		*fields = append(*fields, n.Ident[0])
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			*fields = append(*fields, n.Ident[1])
+		}
+	}
+}
+
+// mappingValue returns the value of key in a YAML mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
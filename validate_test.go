@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("Example configurations", func(t *testing.T) {
+		for _, file := range []string{"simple-mcp.yaml", "unified-core.yaml"} {
+			if problems := ValidateConfig(file); len(problems) != 0 {
+				t.Errorf("%s: unexpected problems: %v", file, problems)
+			}
+		}
+	})
+
+	t.Run("All problems are reported", func(t *testing.T) {
+		dir := t.TempDir()
+		os.MkdirAll(filepath.Join(dir, "simple-mcp.d"), 0755)
+		path := writeTestFile(t, dir, "simple-mcp.yaml", `apiVersion: v2
+kind: DynamicContextSource
+spec:
+  tools:
+    - name: Broken
+      command: "echo {{.host"
+    - name: Typo
+      command: "ping -c 1 {{.hots}}"
+      parameters:
+        - host
+    - name: Broken
+      args: ["ls", "{{.dir}}"]
+      parameters: [dir]
+  resources:
+    - uri: test://file
+      contentFile: missing.txt
+`)
+		writeTestFile(t, dir, "simple-mcp.d/extra.yaml", `tools:
+  - name: Typo
+    command: "true"
+  - name: Slow
+    command: sleep 1
+    timeoutSeconds: soon
+`)
+
+		var got []string
+		for _, problem := range ValidateConfig(path) {
+			got = append(got, strings.TrimPrefix(problem.String(), dir+"/"))
+		}
+		want := []string{
+			`simple-mcp.yaml:1: unsupported apiVersion "v2", expected "v1"`,
+			`simple-mcp.yaml:6: tool Broken: command: invalid template`,
+			`simple-mcp.yaml:8: tool Typo: command: template refers to {{.hots}}, which is not a declared parameter`,
+			`simple-mcp.yaml:11: tool Broken is already defined at ` + path + `:5`,
+			`simple-mcp.yaml:16: resource test://file: content file ` + filepath.Join(dir, "missing.txt") + ` does not exist`,
+			`simple-mcp.d/extra.yaml:2: tool Typo is already defined at ` + path + `:7`,
+			`simple-mcp.d/extra.yaml:6: cannot unmarshal !!str`,
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d problems, got %d:\n%s", len(want), len(got), strings.Join(got, "\n"))
+		}
+		for i := range want {
+			if !strings.HasPrefix(got[i], want[i]) {
+				t.Errorf("problem %d:\n got: %s\nwant: %s", i, got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		path := writeTestFile(t, t.TempDir(), "simple-mcp.yaml", "apiVersion: v1\nspec:\n  tools: [\n")
+		problems := ValidateConfig(path)
+		if len(problems) != 1 || problems[0].Line == 0 {
+			t.Errorf("expected one problem with a line number, got %v", problems)
+		}
+	})
+}